@@ -10,6 +10,8 @@ import (
 	"net"
 	"os"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -30,26 +32,45 @@ type PassiveResult struct {
 }
 
 type ResponseResult struct {
-	Url                    string   `json:"url"`
-	Title                  string   `json:"title"`
-	Server                 string   `json:"server"`
-	Via                    string   `json:"via"`
-	Power                  string   `json:"x-powered-by"`
-	StatusCode             int      `json:"status_code"`
-	Alive                  int      `json:"alive"`
-	ContentLength          int64    `json:"content_length"`
-	ContentLengthByAllBody int64    `json:"content_length_by_all_body"`
-	ResponseHeader         []string `json:"response_header"`
+	Url                    string                   `json:"url"`
+	Scheme                 string                   `json:"scheme"`
+	Port                   int                      `json:"port"`
+	Path                   string                   `json:"path,omitempty"`
+	Title                  string                   `json:"title"`
+	Charset                string                   `json:"charset"`
+	Server                 string                   `json:"server"`
+	Via                    string                   `json:"via"`
+	Power                  string                   `json:"x-powered-by"`
+	StatusCode             int                      `json:"status_code"`
+	Alive                  int                      `json:"alive"`
+	ContentLength          int64                    `json:"content_length"`
+	ContentLengthByAllBody int64                    `json:"content_length_by_all_body"`
+	ContentLengthByWire    int64                    `json:"content_length_by_wire"`
+	Truncated              bool                     `json:"truncated"`
+	Timing                 *httpxUtilz.Timing       `json:"timing"`
+	RemoteIP               string                   `json:"remote_ip"`
+	Attempts               int                      `json:"attempts"`
+	ResponseHeader         []string                 `json:"response_header"`
+	FinalUrl               string                   `json:"final_url"`
+	RedirectChain          []httpxUtilz.RedirectHop `json:"redirect_chain"`
+	FaviconMmh3            string                   `json:"favicon_mmh3,omitempty"`
+	BodySha256             string                   `json:"body_sha256"`
+	BodySimhash            uint64                   `json:"body_simhash"`
+	Technologies           []httpxUtilz.Technology  `json:"technologies"`
 }
 
 type MatchResponseResult struct {
-	MayVul map[string]string `json:"may_vul"`
+	MayVul []httpxUtilz.RuleMatch `json:"may_vul"`
 }
 
 type Result struct {
-	BaseInfo    ResponseResult      `json:"base_info"`
-	PassiveInfo PassiveResult       `json:"passive_info"`
-	RegexInfo   MatchResponseResult `json:"regex_info"`
+	BaseInfo    ResponseResult              `json:"base_info"`
+	TLSInfo     *httpxUtilz.TLSInfo         `json:"tls,omitempty"`
+	WafInfo     *httpxUtilz.WafInfo         `json:"waf,omitempty"`
+	PageInfo    *httpxUtilz.PageInfo        `json:"page,omitempty"`
+	PassiveInfo PassiveResult               `json:"passive_info"`
+	RegexInfo   MatchResponseResult         `json:"regex_info"`
+	Templates   []httpxUtilz.TemplateResult `json:"templates,omitempty"`
 }
 
 type ProcessUrlParams struct {
@@ -64,6 +85,7 @@ type ProcessUrlParams struct {
 	RandomUserAgent bool
 	Headers         string
 	FollowSameHost  bool
+	StopCrossDomain bool
 	Timeout         int
 	Processes       int
 	RateLimit       int
@@ -72,6 +94,45 @@ type ProcessUrlParams struct {
 	Passive         bool
 	Base            bool
 	MayVul          bool
+	Ports           string
+	Paths           string
+	Path            string
+	HostProcesses   int
+	ExpandSans      bool
+	SanDepth        int
+	Scope           string
+	Favicon         bool
+	Tech            bool
+	Waf             bool
+	WafProbe        bool
+	MaxBodySize     int64
+	Retries         int
+	RetryOnStatus   bool
+	HostRateLimit   float64
+	Adaptive        bool
+	RateLimiter     *httpxUtilz.RateLimiter
+	ProxyFile       string
+	ProxyRotation   string
+	ProxyPool       *httpxUtilz.ProxyPool
+	TLSVerify       bool
+	TLSCAFile       string
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSMinVersion   string
+	TLSMaxVersion   string
+	SNI             string
+	VhostIP         string
+	Vhosts          string
+	Rules           string
+	AllowlistFile   string
+	Redact          bool
+	RawFindingsFile string
+	Allowlist       *httpxUtilz.Allowlist
+	Templates       string
+	TemplateList    []*httpxUtilz.Template
+	RunTemplates    bool
+	Origin          bool
+	DNSHistory      string
 }
 
 func readURLsFromFile(filename string) ([]string, error) {
@@ -104,6 +165,61 @@ func isResultEmpty(result Result) bool {
 	return reflect.DeepEqual(result, emptyResult)
 }
 
+// redactResult Mask the secrets of the may vul findings, and every field of the result repeating a matched secret:
+// the urls, headers, redirects, page info, WAF evidence and template extractions.
+func redactResult(result *Result) {
+	redacted := httpxUtilz.RedactMatches(result.RegexInfo.MayVul)
+	var secrets [][2]string
+	for i, match := range result.RegexInfo.MayVul {
+		if redacted[i].Redacted {
+			secrets = append(secrets, [2]string{match.Match, redacted[i].Match})
+		}
+	}
+	result.RegexInfo.MayVul = redacted
+	if len(secrets) == 0 {
+		return
+	}
+
+	// The longest values first, so that a secret holding another one is masked as a whole
+	sort.SliceStable(secrets, func(i, j int) bool { return len(secrets[i][0]) > len(secrets[j][0]) })
+	var pairs []string
+	for _, secret := range secrets {
+		pairs = append(pairs, secret[0], secret[1])
+	}
+	mask := strings.NewReplacer(pairs...).Replace
+
+	base := &result.BaseInfo
+	base.Url, base.Title, base.Server, base.Via, base.Power = mask(base.Url), mask(base.Title), mask(base.Server), mask(base.Via), mask(base.Power)
+	base.FinalUrl = mask(base.FinalUrl)
+	for i := range base.ResponseHeader {
+		base.ResponseHeader[i] = mask(base.ResponseHeader[i])
+	}
+	for i := range base.RedirectChain {
+		base.RedirectChain[i].Url, base.RedirectChain[i].Location = mask(base.RedirectChain[i].Url), mask(base.RedirectChain[i].Location)
+	}
+	if page := result.PageInfo; page != nil {
+		page.Description, page.Keywords, page.Generator = mask(page.Description), mask(page.Keywords), mask(page.Generator)
+		page.Canonical, page.H1 = mask(page.Canonical), mask(page.H1)
+		for key, value := range page.OpenGraph {
+			page.OpenGraph[key] = mask(value)
+		}
+	}
+	if result.WafInfo != nil {
+		for i := range result.WafInfo.Evidence {
+			result.WafInfo.Evidence[i] = mask(result.WafInfo.Evidence[i])
+		}
+	}
+	for i := range result.Templates {
+		template := &result.Templates[i]
+		template.Url = mask(template.Url)
+		for _, values := range template.Extracted {
+			for j := range values {
+				values[j] = mask(values[j])
+			}
+		}
+	}
+}
+
 //func saveResultsToFile(results []Result, resultFile string) {
 //	// The default path for the result file is "./result.json"
 //	if resultFile == "" {
@@ -141,16 +257,34 @@ func WriteBufferToFile(buffer *bytes.Buffer, filePath string) error {
 	if filePath == "" {
 		filePath = "./result.json"
 	}
-	file, err := os.OpenFile(filePath, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, 0666)
+	return writeBufferToFile(buffer, filePath, 0666)
+}
+
+// writeBufferToFile Write the buffer to the file, created with perm. An existing file is restricted to perm too.
+func writeBufferToFile(buffer *bytes.Buffer, filePath string, perm os.FileMode) error {
+	file, err := os.OpenFile(filePath, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, perm)
 	if err != nil {
 		return fmt.Errorf("WriteBufferToFile> failed to create file: %w", err)
 	}
 	defer file.Close()
 
+	// An existing file keeps its mode, a private one is restricted before anything is written
+	if perm&0077 == 0 {
+		if err := file.Chmod(perm); err != nil {
+			return fmt.Errorf("WriteBufferToFile> failed to restrict file: %w", err)
+		}
+	}
+
 	_, err = buffer.WriteTo(file)
 	return err
 }
 
+// RawFindings The unredacted may vul findings of a url, written to the raw findings file only.
+type RawFindings struct {
+	Url    string                 `json:"url"`
+	MayVul []httpxUtilz.RuleMatch `json:"may_vul"`
+}
+
 func UniquerIps(cnameIps, resolveIps []string) (ips []string) {
 	uniqueIPs := make(map[string]bool)
 
@@ -171,10 +305,24 @@ func UniquerIps(cnameIps, resolveIps []string) (ips []string) {
 	return
 }
 
-func processURL(params ProcessUrlParams) (result Result) {
-	config := httpxUtilz.RequestClientConfig{
+// tlsOptions Return the client TLS options of the parameters.
+func tlsOptions(params ProcessUrlParams) httpxUtilz.TLSOptions {
+	return httpxUtilz.TLSOptions{
+		InsecureSkipVerify: !params.TLSVerify,
+		CAFile:             params.TLSCAFile,
+		CertFile:           params.TLSCertFile,
+		KeyFile:            params.TLSKeyFile,
+		MinVersion:         params.TLSMinVersion,
+		MaxVersion:         params.TLSMaxVersion,
+		ServerName:         params.SNI,
+	}
+}
+
+// newRequestConfig Return the request client configuration of the parameters.
+func newRequestConfig(params ProcessUrlParams) httpxUtilz.RequestClientConfig {
+	return httpxUtilz.RequestClientConfig{
 		ProxyURL:        params.Proxy,
-		UseHTTPS:        params.UseHTTPS,
+		TLS:             tlsOptions(params),
 		FollowRedirects: params.FollowRedirects,
 		MaxRedirects:    params.MaxRedirects,
 		Method:          params.Method,
@@ -182,12 +330,33 @@ func processURL(params ProcessUrlParams) (result Result) {
 		Headers: map[string]string{
 			"User-Agent": params.Headers,
 		},
-		FollowSameHost: params.FollowSameHost,
-		Timeout:        time.Duration(params.Timeout),
+		FollowSameHost:  params.FollowSameHost,
+		StopCrossDomain: params.StopCrossDomain,
+		Timeout:         time.Duration(params.Timeout),
+		MaxBodySize:     params.MaxBodySize,
+		Retries:         params.Retries,
+		RetryOnStatus:   params.RetryOnStatus,
+		RateLimiter:     params.RateLimiter,
+		ProxyPool:       params.ProxyPool,
+	}
+}
+
+func processURL(params ProcessUrlParams) (result Result) {
+	var err error
+
+	config := newRequestConfig(params)
+
+	// Targets expanded by port carry no scheme, detect whether TLS is spoken.
+	params.Url, err = config.DetectUrlScheme(params.Url)
+	if err != nil {
+		log.Println("processURL>  detect scheme error: ", err)
+		return
 	}
+	scheme, port := httpxUtilz.GetSchemePortByUrl(params.Url)
 
 	var (
 		title                  string
+		charsetName            string
 		server                 string
 		via                    string
 		power                  string
@@ -195,9 +364,21 @@ func processURL(params ProcessUrlParams) (result Result) {
 		alive                  int
 		contentLength          int64
 		contentLengthByAllBody int64
+		contentLengthByWire    int64
+		truncated              bool
+		timing                 *httpxUtilz.Timing
+		remoteIP               string
+		attempts               int
 		responseHeader         []string
+		finalUrl               string
+		redirectChain          []httpxUtilz.RedirectHop
+		tlsInfo                *httpxUtilz.TLSInfo
+		pageInfo               *httpxUtilz.PageInfo
+		faviconMmh3            string
+		bodySha256             string
+		bodySimhash            uint64
+		technologies           []httpxUtilz.Technology
 		resp                   *httpxUtilz.Response
-		err                    error
 	)
 
 	if params.Base {
@@ -208,17 +389,36 @@ func processURL(params ProcessUrlParams) (result Result) {
 		}
 
 		title = config.GetTitleByResponse(resp)
+		charsetName = config.GetCharsetByResponse(resp)
 		server, via, power = config.GetBannerByResponse(resp)
 		statusCode = config.GetStatusByResponse(resp)
 		alive = config.GetAliveByResponse(resp)
 		contentLength = config.GetContentLengthByResponse(resp)
 		contentLengthByAllBody = config.GetContentLengthAllBodyByResponse(resp)
+		contentLengthByWire, truncated = config.GetContentLengthWireByResponse(resp)
+		timing, remoteIP = config.GetTimingByResponse(resp)
+		attempts = config.GetAttemptsByResponse(resp)
 		responseHeader = config.GetServerAllHeaderByResponse(resp)
+		finalUrl = config.GetFinalUrlByResponse(resp)
+		redirectChain = config.GetRedirectChainByResponse(resp)
+		tlsInfo = config.GetTLSInfoByResponse(resp)
+		pageInfo = config.GetPageInfoByResponse(resp)
+		bodySha256, bodySimhash = config.GetBodyHashByResponse(resp)
+		if params.Favicon {
+			faviconMmh3 = config.GetFaviconHashByResponse(resp)
+		}
+		if params.Tech {
+			technologies = config.GetTechnologiesByResponse(resp, "./data/technologies.json")
+		}
 	}
 
 	baseInfo := ResponseResult{
 		Url:                    params.Url,
+		Scheme:                 scheme,
+		Port:                   port,
+		Path:                   params.Path,
 		Title:                  title,
+		Charset:                charsetName,
 		Server:                 server,
 		Via:                    via,
 		Power:                  power,
@@ -226,7 +426,18 @@ func processURL(params ProcessUrlParams) (result Result) {
 		Alive:                  alive,
 		ContentLength:          contentLength,
 		ContentLengthByAllBody: contentLengthByAllBody,
+		ContentLengthByWire:    contentLengthByWire,
+		Truncated:              truncated,
+		Timing:                 timing,
+		RemoteIP:               remoteIP,
+		Attempts:               attempts,
 		ResponseHeader:         responseHeader,
+		FinalUrl:               finalUrl,
+		RedirectChain:          redirectChain,
+		FaviconMmh3:            faviconMmh3,
+		BodySha256:             bodySha256,
+		BodySimhash:            bodySimhash,
+		Technologies:           technologies,
 	}
 
 	var (
@@ -245,7 +456,8 @@ func processURL(params ProcessUrlParams) (result Result) {
 		if len(ips) == 0 {
 			return Result{}
 		}
-		cidr, asn, org, addr := config.GetAsnInfoByIp(ips, params.Proxy)
+		proxy, _ := config.GetProxyURL()
+		cidr, asn, org, addr := config.GetAsnInfoByIp(ips, proxy)
 
 		if len(ips) > 0 {
 
@@ -280,6 +492,18 @@ func processURL(params ProcessUrlParams) (result Result) {
 		}
 	}
 
+	var wafInfo *httpxUtilz.WafInfo
+	if params.Waf {
+		if resp == nil { // not get baseinfo, but waf detection need response
+			resp, err = config.GetResponseByUrl(params.Url)
+			if err != nil {
+				log.Println("processURL>  request error: ", err)
+				return
+			}
+		}
+		wafInfo = config.GetWafInfoByAll(resp, params.Url, params.WafProbe, "./data/waf_signatures.json")
+	}
+
 	var (
 		matchResponseResult MatchResponseResult
 	)
@@ -292,222 +516,363 @@ func processURL(params ProcessUrlParams) (result Result) {
 				return
 			}
 		}
-		matchResponseResult.MayVul = config.GetMayVulInfoByRespone(resp, "./data/regex_MayVul.json")
+		matchResponseResult.MayVul = config.GetMayVulInfoByRespone(resp, params.Rules, params.Allowlist)
+	}
+
+	// Templates request their own paths, they run once per url and not for every path of -paths
+	var templates []httpxUtilz.TemplateResult
+	if len(params.TemplateList) > 0 && params.RunTemplates {
+		templates = config.RunTemplates(params.Url, params.TemplateList)
 	}
 
 	result = Result{
 		BaseInfo:    baseInfo,
+		TLSInfo:     tlsInfo,
+		WafInfo:     wafInfo,
+		PageInfo:    pageInfo,
 		PassiveInfo: passiveInfos,
 		RegexInfo:   matchResponseResult,
+		Templates:   templates,
 	}
 	return
 }
 
-func ProcessURLFromLine(params ProcessUrlParams) {
-	// Create a wait group to wait for all Goroutines to complete
-	var wg sync.WaitGroup
-
-	// Create a channel to limit the rate of requests
-	rateLimiter := time.Tick(time.Second / time.Duration(params.RateLimit))
-
-	// Create a list of results
-	results := make([]Result, 0)
-
-	// Create a buffer to store the results temporarily
-	var buffer bytes.Buffer
-
-	// Initiate multiple Goroutines for concurrent processing
-	for i := 0; i < 1; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
+// processTemplates Run the templates alone on the url, the templates target of a url probed with -paths.
+func processTemplates(params ProcessUrlParams) (result Result) {
+	config := newRequestConfig(params)
 
-			// Retrieve a token from the channel to control the rate
-			<-rateLimiter
+	url, err := config.DetectUrlScheme(params.Url)
+	if err != nil {
+		log.Println("processTemplates>  detect scheme error: ", err)
+		return
+	}
+	templates := config.RunTemplates(url, params.TemplateList)
+	if len(templates) == 0 {
+		return
+	}
+	scheme, port := httpxUtilz.GetSchemePortByUrl(url)
+	return Result{
+		BaseInfo:  ResponseResult{Url: url, Scheme: scheme, Port: port},
+		Templates: templates,
+	}
+}
 
-			// Perform the request and processing
-			result := processURL(params)
+// probeTarget A single url to probe, with the path from the path list that produced it.
+// Templates is set on one target per url, the one which runs the templates. With -paths the templates
+// get a TemplatesOnly target of their own, so that they don't depend on the probe of a path.
+type probeTarget struct {
+	Url           string
+	Path          string
+	Templates     bool
+	TemplatesOnly bool
+}
 
-			if !isResultEmpty(result) {
-				// Add the result to the list.
-				results = append(results, result)
+// hostSemaphores Limit the number of concurrent requests sent to the same host.
+type hostSemaphores struct {
+	mu    sync.Mutex
+	size  int
+	slots map[string]chan struct{}
+}
 
-				jsonData, err := json.Marshal(result)
-				if err != nil {
-					log.Println("ProcessURLFromLine> json marshal error:", err)
-					return
-				}
+func newHostSemaphores(size int) *hostSemaphores {
+	return &hostSemaphores{
+		size:  size,
+		slots: make(map[string]chan struct{}),
+	}
+}
 
-				fmt.Println(string(jsonData))
+func (h *hostSemaphores) acquire(host string) {
+	if h.size < 1 {
+		return
+	}
+	h.mu.Lock()
+	slot, ok := h.slots[host]
+	if !ok {
+		slot = make(chan struct{}, h.size)
+		h.slots[host] = slot
+	}
+	h.mu.Unlock()
 
-				buffer.WriteString(string(jsonData))
-				buffer.WriteString("\n")
-			} else {
-				log.Println(params.Url + " can't get result")
-			}
+	slot <- struct{}{}
+}
 
-		}()
+func (h *hostSemaphores) release(host string) {
+	if h.size < 1 {
+		return
 	}
+	h.mu.Lock()
+	slot := h.slots[host]
+	h.mu.Unlock()
 
-	// Wait for all Goroutines to complete
-	wg.Wait()
+	<-slot
+}
 
-	// Save the results to a JSON file
-	if params.Res && buffer.Len() > 0 {
-		//saveResultsToFile(results, params.ResultFile)
-		err := WriteBufferToFile(&buffer, params.ResultFile)
+// expandTargets Turn the input urls into the list of targets to probe.
+func expandTargets(params ProcessUrlParams, urls []string) (targets []probeTarget, err error) {
+	if params.Ports != "" {
+		ports, err := httpxUtilz.ParsePorts(params.Ports)
 		if err != nil {
-			fmt.Println("WriteBufferToFile Error:", err)
-			return
+			return nil, fmt.Errorf("expandTargets> %w", err)
 		}
+		var portUrls []string
+		for _, url := range urls {
+			portUrls = append(portUrls, httpxUtilz.ExpandUrlByPorts(url, ports)...)
+		}
+		urls = httpxUtilz.UniqueStrList(portUrls)
 	}
-}
-
-func ProcessURLFromGroup(params ProcessUrlParams) {
-	// Create a wait group to wait for all Goroutines to complete
-	var wg sync.WaitGroup
-
-	// Create a channel to limit the rate of requests
-	rateLimiter := time.Tick(time.Second / time.Duration(params.RateLimit))
-
-	// Create a list of results
-	results := make([]Result, 0)
 
-	// Create a buffer to store the results temporarily
-	var buffer bytes.Buffer
-
-	urls, err := readURLsFromFile(params.Filename)
-	if err != nil {
-		log.Println("ProcessURLFromGroup> failed to read URLs from file:", err)
+	if params.Paths == "" {
+		for _, url := range urls {
+			targets = append(targets, probeTarget{Url: url, Templates: true})
+		}
 		return
 	}
 
-	// Create a Goroutine pool to limit the concurrency
-	pool := &sync.Pool{
-		New: func() interface{} {
-			return make(chan struct{}, params.Processes)
-		},
+	// Iterate paths first, so that consecutive targets hit different hosts.
+	for _, path := range httpxUtilz.ParsePaths(params.Paths) {
+		for _, url := range urls {
+			targets = append(targets, probeTarget{Url: httpxUtilz.JoinUrlPath(url, path), Path: path})
+		}
 	}
+	if len(params.TemplateList) > 0 {
+		for _, url := range urls {
+			targets = append(targets, probeTarget{Url: url, Templates: true, TemplatesOnly: true})
+		}
+	}
+	return
+}
 
-	// Initiate multiple Goroutines for concurrent processing
-	for _, url := range urls {
-		wg.Add(1)
-		go func(url string) {
-			defer wg.Done()
-
-			// Retrieve a token from the channel to control the rate
-			<-rateLimiter
-
-			//Retrieve a Goroutine from the Goroutine pool
-			token := pool.Get().(chan struct{})
-
-			// Perform the request and processing
-			params.Url = url
-			result := processURL(params)
-
-			if !isResultEmpty(result) {
-				// Add the result to the list
-				results = append(results, result)
-
-				jsonData, err := json.Marshal(result)
-				if err != nil {
-					log.Println("ProcessURLFromGroup> json marshal error:", err)
-					return
-				}
+// newProxyPool Build the proxy pool from -proxy and -proxy-file.
+func newProxyPool(params ProcessUrlParams) (*httpxUtilz.ProxyPool, error) {
+	proxies := []string{params.Proxy}
+	if params.ProxyFile != "" {
+		fileProxies, err := httpxUtilz.LoadProxyFile(params.ProxyFile)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, fileProxies...)
+	}
 
-				fmt.Println(string(jsonData))
+	pool, err := httpxUtilz.NewProxyPool(proxies, params.ProxyRotation)
+	if err != nil {
+		return nil, err
+	}
+	if params.ProxyFile != "" {
+		timeout := time.Duration(params.Timeout) * time.Second
+		if timeout == 0 {
+			timeout = 10 * time.Second
+		}
+		pool.CheckHealth(timeout)
+	}
+	return pool, nil
+}
 
-				buffer.WriteString(string(jsonData))
-				buffer.WriteString("\n")
-			} else {
-				log.Println(url + " can't get result")
-			}
+// prepareRun Check the TLS options and the ports, load the allowlist and the templates and set up the proxy pool and the rate limiter shared by a run.
+func prepareRun(params *ProcessUrlParams) error {
+	// Fail fast on unreadable certificates or bad versions instead of failing every request
+	if _, err := httpxUtilz.LoadTLSConfig(tlsOptions(*params)); err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
 
-			// Release the Goroutine back to the Goroutine pool
-			pool.Put(token)
-		}(url)
+	// A bad port list would scan the urls on their own port only
+	if params.Ports != "" {
+		if _, err := httpxUtilz.ParsePorts(params.Ports); err != nil {
+			return fmt.Errorf("ports: %w", err)
+		}
 	}
 
-	// Wait for all Goroutines to complete
-	wg.Wait()
+	// Spread the requests over the proxies, a proxy list is health checked before the scan starts
+	if params.Proxy != "" || params.ProxyFile != "" {
+		pool, err := newProxyPool(*params)
+		if err != nil {
+			return fmt.Errorf("proxy: %w", err)
+		}
+		params.ProxyPool = pool
+	}
 
-	// Save the results to a JSON file
-	if params.Res && buffer.Len() > 0 {
-		//saveResultsToFile(results, params.ResultFile)
-		err := WriteBufferToFile(&buffer, params.ResultFile)
+	if params.AllowlistFile != "" {
+		allowlist, err := httpxUtilz.LoadAllowlist(params.AllowlistFile)
 		if err != nil {
-			fmt.Println("WriteBufferToFile Error:", err)
-			return
+			return fmt.Errorf("allowlist: %w", err)
 		}
+		params.Allowlist = allowlist
 	}
-}
 
-func ProcessURLFromPipe(params ProcessUrlParams) {
-	// Create a wait group to wait for all Goroutines to complete
-	var wg sync.WaitGroup
+	// Broken templates are reported before the scan rather than skipped silently
+	if params.Templates != "" {
+		templates, err := httpxUtilz.LoadTemplates(params.Templates)
+		if err != nil {
+			return fmt.Errorf("templates: %w", err)
+		}
+		params.TemplateList = templates
+	}
 
-	// Create a channel to limit the rate of requests
-	rateLimiter := time.Tick(time.Second / time.Duration(params.RateLimit))
+	// Every request, retries and favicon fetches included, waits for the global and the per host token buckets
+	params.RateLimiter = httpxUtilz.NewRateLimiter(float64(params.RateLimit), params.HostRateLimit, params.Adaptive)
+	return nil
+}
 
-	// Create a list of results
-	results := make([]Result, 0)
+// processTargets Process every url concurrently, print each result and save them if required.
+// With ExpandSans, in scope certificate SAN hostnames are probed in further rounds up to SanDepth.
+func processTargets(params ProcessUrlParams, urls []string) {
+	if err := prepareRun(&params); err != nil {
+		log.Println("processTargets> ", err)
+		return
+	}
 
-	// Create a buffer to store the results temporarily
+	// Create a buffer to store the results temporarily, shared by all Goroutines
 	var buffer bytes.Buffer
+	var bufferMu sync.Mutex
+	// The unredacted findings, only kept with -raw-findings
+	var rawBuffer bytes.Buffer
+
+	// Create a semaphore to limit the concurrency
+	processes := params.Processes
+	if processes < 1 {
+		processes = 1
+	}
+	semaphore := make(chan struct{}, processes)
+
+	// The per host concurrency is capped separately from the global one
+	hostLimiter := newHostSemaphores(params.HostProcesses)
+
+	// CDN fronted targets and what may reveal their origin, needs the passive CDN detection
+	var origins *originTracker
+	if params.Origin {
+		if params.Passive {
+			origins = newOriginTracker()
+		} else {
+			log.Println("processTargets> -origin requires -passive, origin discovery skipped")
+		}
+	}
 
-	// Create a Goroutine pool to limit the concurrency
-	pool := &sync.Pool{
-		New: func() interface{} {
-			return make(chan struct{}, params.Processes)
-		},
+	// Every host already scanned, so that SAN expansion never probes it twice
+	scanned := make(map[string]bool)
+	for _, url := range urls {
+		if host, err := httpxUtilz.GetSubDomain(url); err == nil {
+			scanned[strings.ToLower(host)] = true
+		}
+	}
+
+	scope := httpxUtilz.UniqueStrList(strings.Split(params.Scope, ","))
+	if len(scope) == 0 {
+		scope = httpxUtilz.GetScopeByUrls(urls)
 	}
 
-	// Initiate multiple Goroutines for concurrent processing
-	for _, url := range params.URLPipe {
-		wg.Add(1)
-		go func(url string) {
-			defer wg.Done()
+	for depth := 0; len(urls) > 0; depth++ {
+		// Create a wait group to wait for all Goroutines of this round to complete
+		var wg sync.WaitGroup
+		var sans []string
 
-			// Retrieve a token from the channel to control the rate
-			<-rateLimiter
+		targets, err := expandTargets(params, urls)
+		if err != nil {
+			log.Println("processTargets> ", err)
+			break
+		}
 
-			// Retrieve a Goroutine from the Goroutine pool
-			token := pool.Get().(chan struct{})
+		// Initiate multiple Goroutines for concurrent processing
+		for _, target := range targets {
+			wg.Add(1)
+			semaphore <- struct{}{}
+			go func(params ProcessUrlParams, target probeTarget) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+
+				host, _ := httpxUtilz.GetSubDomain(target.Url)
+				hostLimiter.acquire(host)
+				defer hostLimiter.release(host)
+
+				// Perform the request and processing
+				params.Url = target.Url
+				params.Path = target.Path
+				params.RunTemplates = target.Templates
+				var result Result
+				if target.TemplatesOnly {
+					result = processTemplates(params)
+				} else {
+					result = processURL(params)
+				}
 
-			// Perform the request and processing
-			params.Url = url
-			result := processURL(params)
+				if isResultEmpty(result) {
+					// No template matched
+					if !target.TemplatesOnly {
+						log.Println(target.Url + " can't get result")
+					}
+					return
+				}
 
-			if !isResultEmpty(result) {
-				// Add the result to the list
-				results = append(results, result)
+				var rawData []byte
+				if params.RawFindingsFile != "" && len(result.RegexInfo.MayVul) > 0 {
+					rawData, _ = json.Marshal(RawFindings{Url: target.Url, MayVul: result.RegexInfo.MayVul})
+				}
+				if params.Redact {
+					redactResult(&result)
+				}
 
 				jsonData, err := json.Marshal(result)
 				if err != nil {
-					log.Println("ProcessURLFromPipe> json marshal error:", err)
+					log.Println("processTargets> json marshal error:", err)
 					return
 				}
 
+				bufferMu.Lock()
+				defer bufferMu.Unlock()
+
+				if rawData != nil {
+					rawBuffer.Write(rawData)
+					rawBuffer.WriteString("\n")
+				}
+
 				fmt.Println(string(jsonData))
 
 				buffer.WriteString(string(jsonData))
 				buffer.WriteString("\n")
-			} else {
-				log.Println(url + " can't get result")
-				return
+
+				if result.TLSInfo != nil {
+					sans = append(sans, result.TLSInfo.SANs...)
+				}
+				if origins != nil {
+					origins.observe(result.BaseInfo.Url, result)
+				}
+			}(params, target)
+		}
+
+		// Wait for all Goroutines to complete
+		wg.Wait()
+
+		if !params.ExpandSans || depth >= params.SanDepth {
+			break
+		}
+
+		// Enqueue the new in scope SAN hostnames as targets of the next round
+		urls = nil
+		for _, host := range httpxUtilz.GetHostsBySANs(sans) {
+			if scanned[host] || !httpxUtilz.InScope(host, scope) {
+				continue
 			}
+			scanned[host] = true
+			urls = append(urls, host)
+		}
+		if len(urls) > 0 {
+			log.Printf("processTargets> depth %d: %d new hosts from certificate SANs", depth+1, len(urls))
+		}
+	}
 
-			// Release the Goroutine back to the Goroutine pool
-			pool.Put(token)
-		}(url)
+	if origins != nil {
+		origins.discover(params, &buffer)
 	}
 
-	// Wait for all Goroutines to complete
-	wg.Wait()
+	httpxUtilz.CloseIdleConnections()
+
+	// Readable by the owner only, it holds the secrets in clear text
+	if params.RawFindingsFile != "" && rawBuffer.Len() > 0 {
+		if err := writeBufferToFile(&rawBuffer, params.RawFindingsFile, 0600); err != nil {
+			fmt.Println("WriteBufferToFile Error:", err)
+		}
+	}
 
 	// Save the results to a JSON file
 	if params.Res && buffer.Len() > 0 {
-		//saveResultsToFile(results, params.ResultFile)
 		err := WriteBufferToFile(&buffer, params.ResultFile)
 		if err != nil {
 			fmt.Println("WriteBufferToFile Error:", err)
@@ -515,3 +880,21 @@ func ProcessURLFromPipe(params ProcessUrlParams) {
 		}
 	}
 }
+
+func ProcessURLFromLine(params ProcessUrlParams) {
+	processTargets(params, []string{params.Url})
+}
+
+func ProcessURLFromGroup(params ProcessUrlParams) {
+	urls, err := readURLsFromFile(params.Filename)
+	if err != nil {
+		log.Println("ProcessURLFromGroup> failed to read URLs from file:", err)
+		return
+	}
+
+	processTargets(params, urls)
+}
+
+func ProcessURLFromPipe(params ProcessUrlParams) {
+	processTargets(params, params.URLPipe)
+}
@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"httpxUtilz/cmd"
+	"log"
 	"os"
 )
 
@@ -13,15 +14,32 @@ var params cmd.ProcessUrlParams
 func init() {
 	flag.StringVar(&params.Url, "url", "", "URL to process.")
 	flag.StringVar(&params.Filename, "urls", "", "File URLs to process.")
-	flag.StringVar(&params.Proxy, "proxy", "", "Proxy URL.")
-	flag.BoolVar(&params.UseHTTPS, "usehttps", true, "Initiate an HTTPS request.")
+	flag.StringVar(&params.Proxy, "proxy", "", "Proxy URL: http, https, socks5 (local DNS) or socks5h (remote DNS).")
+	flag.StringVar(&params.ProxyFile, "proxy-file", "", "File of proxy URLs, one per line, requests are spread over them.")
+	flag.StringVar(&params.ProxyRotation, "proxy-rotation", "round-robin", "Proxy rotation: round-robin or random.")
+	flag.BoolVar(&params.UseHTTPS, "usehttps", true, "Deprecated: use -tls-verify, -usehttps=false means -tls-verify.")
+	flag.BoolVar(&params.TLSVerify, "tls-verify", false, "Verify the server certificates, failing the request on an invalid one.")
+	flag.StringVar(&params.TLSCAFile, "tls-ca", "", "PEM CA bundle certificates are verified against, instead of the system roots.")
+	flag.StringVar(&params.TLSCertFile, "tls-cert", "", "PEM client certificate, for mutual TLS.")
+	flag.StringVar(&params.TLSKeyFile, "tls-key", "", "PEM client private key, for mutual TLS.")
+	flag.StringVar(&params.TLSMinVersion, "tls-min-version", "", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3.")
+	flag.StringVar(&params.TLSMaxVersion, "tls-max-version", "", "Maximum TLS version: 1.0, 1.1, 1.2 or 1.3.")
+	flag.BoolVar(&params.Origin, "origin", false, "Look for the origin IPs of CDN fronted targets and verify them, requires -passive.")
+	flag.StringVar(&params.DNSHistory, "dns-history", "", "File of historical A records, \"hostname ip\" per line, used by -origin.")
+	flag.StringVar(&params.VhostIP, "vhost-ip", "", "Virtual host mode: IP[:port] every candidate hostname is sent to.")
+	flag.StringVar(&params.Vhosts, "vhosts", "", "File or comma separated list of candidate hostnames for -vhost-ip, default the input.")
+	flag.StringVar(&params.SNI, "sni", "", "TLS server name sent and verified, instead of the URL host.")
 	flag.BoolVar(&params.FollowRedirects, "followredirects", false, "Perform a URL request redirection.")
 	flag.IntVar(&params.MaxRedirects, "maxredirects", 0, "Maximum number of redirections.")
 	flag.StringVar(&params.Method, "method", "GET", "The default request method is GET.")
 	flag.BoolVar(&params.RandomUserAgent, "randomuseragent", true, "Whether to use a random User-Agent header.")
 	flag.StringVar(&params.Headers, "headers", "", "Customize the request headers.")
 	flag.BoolVar(&params.FollowSameHost, "followsamehost", false, "Follow Same Host.")
+	flag.BoolVar(&params.StopCrossDomain, "stopcrossdomain", false, "Stop following redirects to another registrable domain, the hop is still recorded.")
 	flag.IntVar(&params.Timeout, "timeout", 10, "Request url timeout.")
+	flag.IntVar(&params.Retries, "retries", 0, "Retries on connect errors and timeouts, with exponential backoff and jitter.")
+	flag.BoolVar(&params.RetryOnStatus, "retry-status", false, "Also retry on 429, 502 and 503 responses, honoring Retry-After.")
+	flag.Int64Var(&params.MaxBodySize, "max-body-size", 10<<20, "Maximum bytes of response body read and decoded, larger bodies are truncated.")
 	flag.IntVar(&params.Processes, "processes", 1, "Number of processes.")
 	flag.IntVar(&params.RateLimit, "rateLimit", 50, "Rate limit.")
 	flag.BoolVar(&params.Res, "res", false, "Default not save result.")
@@ -29,7 +47,34 @@ func init() {
 	flag.BoolVar(&params.Base, "base", true, "Default not get base info data.")
 	flag.BoolVar(&params.Passive, "passive", false, "Default not get passive info data.")
 	flag.BoolVar(&params.MayVul, "mayvul", false, "Default not get may vul info data.")
+	flag.StringVar(&params.Rules, "rules", "./data/rules_MayVul.json", "May vul rules file, structured JSON or YAML, or the legacy flat JSON.")
+	flag.StringVar(&params.AllowlistFile, "allowlist", "", "File of may vul false positives to drop, one sha256:<hex>, regex:<pattern> or url:<glob> per line.")
+	flag.BoolVar(&params.Redact, "redact", false, "Mask the middle of the secrets found by -mayvul in the output and the result file, their SHA-256 is kept.")
+	flag.StringVar(&params.RawFindingsFile, "raw-findings", "", "Opt-in file, readable by the owner only, of the unredacted may vul findings.")
+	flag.StringVar(&params.Templates, "templates", "", "Comma separated check template files or directories, e.g. ./data/templates.")
+	flag.BoolVar(&params.Favicon, "favicon", false, "Fetch the favicon and report its mmh3 hash.")
+	flag.BoolVar(&params.Tech, "tech", false, "Fingerprint technologies with ./data/technologies.json.")
+	flag.BoolVar(&params.Waf, "waf", false, "Detect the WAF from cookies, headers and block pages with ./data/waf_signatures.json.")
+	flag.BoolVar(&params.WafProbe, "waf-probe", false, "Send one benign attack looking request to confirm the WAF.")
+	flag.StringVar(&params.Ports, "ports", "", "Ports to probe on each host, e.g. 80,443,8000-8010,http-common.")
+	flag.StringVar(&params.Paths, "paths", "", "File or comma separated list of paths to probe on each URL.")
+	flag.Float64Var(&params.HostRateLimit, "host-rate", 0, "Maximum requests per second per host, 0 means no per host limit.")
+	flag.BoolVar(&params.Adaptive, "adaptive", false, "Slow down a host on 429 responses and connection resets.")
+	flag.IntVar(&params.HostProcesses, "host-processes", 0, "Maximum concurrent requests per host, 0 means no per host limit.")
+	flag.BoolVar(&params.ExpandSans, "expand-sans", false, "Probe in scope hostnames found in certificate SANs as new targets.")
+	flag.IntVar(&params.SanDepth, "san-depth", 1, "Maximum rounds of certificate SAN expansion.")
+	flag.StringVar(&params.Scope, "scope", "", "Comma separated domains allowed for SAN expansion, default the registrable domains of the input.")
 	flag.Parse()
+
+	// -usehttps only ever toggled the certificate verification
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "usehttps" {
+			log.Println("-usehttps is deprecated, use -tls-verify")
+			if !params.UseHTTPS {
+				params.TLSVerify = true
+			}
+		}
+	})
 }
 
 func main() {
@@ -45,6 +90,11 @@ func main() {
 			fmt.Println("Unable to read from the pipe input:", err)
 			return
 		}
+	}
+
+	if params.VhostIP != "" {
+		cmd.ProcessVhosts(params)
+	} else if len(params.URLPipe) > 0 {
 		cmd.ProcessURLFromPipe(params)
 	} else {
 		if params.Url != "" {
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"encoding/json"
+	httpxUtilz "httpxUtilz/utilz"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplatesWithPaths(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/app/.env" {
+			w.Write([]byte("DB_PASSWORD=hunter2\n"))
+			return
+		}
+		// The probes of the paths fail
+		panic(http.ErrAbortHandler)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "env.yaml")
+	if err := os.WriteFile(file, []byte(`id: env
+http:
+  - path: ["{{BaseURL}}/.env"]
+    matchers:
+      - type: word
+        words: [DB_PASSWORD]
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	params := ProcessUrlParams{Base: true, Paths: "/admin,/login", Templates: file, Timeout: 5}
+	if err := prepareRun(&params); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each url gets a target of its own for the templates, the path targets don't run them
+	targets, err := expandTargets(params, []string{server.URL + "/app", server.URL + "/other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := make(map[string]int)
+	for _, target := range targets {
+		if target.Templates {
+			if !target.TemplatesOnly || target.Path != "" {
+				t.Errorf("path target running the templates: %+v", target)
+			}
+			runs[target.Url]++
+		}
+	}
+	if len(targets) != 6 || runs[server.URL+"/app"] != 1 || runs[server.URL+"/other"] != 1 {
+		t.Fatalf("unexpected targets: %+v", targets)
+	}
+
+	// The templates run on the url even though every path fails
+	resultFile := filepath.Join(dir, "result.json")
+	params.Res, params.ResultFile = true, resultFile
+	processTargets(params, []string{server.URL + "/app"})
+	data, err := os.ReadFile(resultFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("%v:\n%s", err, data)
+	}
+	if result.BaseInfo.Url != server.URL+"/app" || len(result.Templates) != 1 || result.Templates[0].Url != server.URL+"/app/.env" {
+		t.Errorf("unexpected result: %s", data)
+	}
+
+	httpxUtilz.CloseIdleConnections()
+}
+
+func TestInvalidPorts(t *testing.T) {
+	params := ProcessUrlParams{Ports: "80,http-unknown", Timeout: 5}
+	if err := prepareRun(&params); err == nil {
+		t.Error("prepareRun should reject the port list")
+	}
+	if targets, err := expandTargets(params, []string{"http://example.com"}); err == nil {
+		t.Errorf("expandTargets should reject the port list, got %+v", targets)
+	}
+}
+
+func TestOriginSchemelessTarget(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Cache", "HIT")
+		w.Write([]byte("fronted"))
+	}))
+	defer server.Close()
+
+	// processURL reads its data files relative to the repository root
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	dir := t.TempDir()
+	history := filepath.Join(dir, "history.txt")
+	if err := os.WriteFile(history, []byte("localhost 127.0.0.2 2020-01-01\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	resultFile := filepath.Join(dir, "result.json")
+	params := ProcessUrlParams{Base: true, Passive: true, Origin: true, DNSHistory: history, Res: true, ResultFile: resultFile, Timeout: 5}
+	processTargets(params, []string{strings.Replace(server.URL, "http://127.0.0.1", "localhost", 1)})
+
+	data, err := os.ReadFile(resultFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"origin_info"`) || !strings.Contains(string(data), `"127.0.0.2"`) {
+		t.Errorf("no origin candidate for the scheme-less target:\n%s", data)
+	}
+}
+
+func TestRedactResult(t *testing.T) {
+	const secret = "Zk3mQ9vT2xLp8wRb"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Session-Token", secret)
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/home?session="+secret, http.StatusFound)
+			return
+		}
+		w.Write([]byte("welcome"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	rules := filepath.Join(dir, "rules.yaml")
+	if err := os.WriteFile(rules, []byte(`rules:
+  - id: session-token
+    name: Session Token
+    scope: header
+    header: X-Session-Token
+    tags: [secret]
+    patterns: ['[A-Za-z0-9]{16}']
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	template := filepath.Join(dir, "session.yaml")
+	if err := os.WriteFile(template, []byte(`id: session
+http:
+  - path: ["{{BaseURL}}/home"]
+    matchers:
+      - type: status
+        status: [200]
+    extractors:
+      - type: kval
+        kval: [x_session_token]
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	params := ProcessUrlParams{Base: true, MayVul: true, Rules: rules, Templates: template, Redact: true,
+		FollowRedirects: true, FollowSameHost: true, Timeout: 5}
+	if err := prepareRun(&params); err != nil {
+		t.Fatal(err)
+	}
+	params.Url, params.RunTemplates = server.URL, true
+	result := processURL(params)
+	if len(result.RegexInfo.MayVul) != 1 || len(result.Templates) != 1 || len(result.BaseInfo.RedirectChain) != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	redactResult(&result)
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Errorf("the secret is left in clear:\n%s", data)
+	}
+	if masked := httpxUtilz.RedactSecret(secret); !strings.Contains(result.BaseInfo.FinalUrl, masked) || result.Templates[0].Extracted["extracted"][0] != masked {
+		t.Errorf("got final url %s and extractions %v", result.BaseInfo.FinalUrl, result.Templates[0].Extracted)
+	}
+
+	httpxUtilz.CloseIdleConnections()
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	httpxUtilz "httpxUtilz/utilz"
+	"log"
+	"strings"
+	"sync"
+)
+
+type OriginResult struct {
+	OriginInfo *httpxUtilz.OriginInfo `json:"origin_info"`
+}
+
+// frontedTarget A target found behind a CDN, with the CDN IPs it resolved to.
+type frontedTarget struct {
+	Url string
+	IPs []string
+}
+
+// certificateHost The IPs of a non CDN target and the SANs of the certificate it served.
+type certificateHost struct {
+	IPs  []string
+	SANs []string
+}
+
+// originTracker Collect what the scan learns about CDN fronted targets and their non CDN siblings,
+// the origin candidates are looked for once every target was probed.
+type originTracker struct {
+	mu       sync.Mutex
+	fronted  map[string]frontedTarget
+	siblings map[string][]string
+	certs    []certificateHost
+}
+
+func newOriginTracker() *originTracker {
+	return &originTracker{
+		fronted:  make(map[string]frontedTarget),
+		siblings: make(map[string][]string),
+	}
+}
+
+// observe Record the CDN status, IPs and certificate of a result.
+func (o *originTracker) observe(targetUrl string, result Result) {
+	host, err := httpxUtilz.GetSubDomain(targetUrl)
+	if err != nil || len(result.PassiveInfo.IP) == 0 {
+		return
+	}
+	host = strings.ToLower(host)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if result.PassiveInfo.Cdn == 1 {
+		// Ports and paths of the same host share their origin
+		if _, ok := o.fronted[host]; !ok {
+			o.fronted[host] = frontedTarget{Url: targetUrl, IPs: result.PassiveInfo.IP}
+		}
+		return
+	}
+
+	domain := httpxUtilz.GetRegistrableDomain(host)
+	o.siblings[domain] = append(o.siblings[domain], result.PassiveInfo.IP...)
+	if result.TLSInfo != nil && len(result.TLSInfo.SANs) > 0 {
+		o.certs = append(o.certs, certificateHost{IPs: result.PassiveInfo.IP, SANs: result.TLSInfo.SANs})
+	}
+}
+
+// candidates Return the origin candidates of the fronted host from every source, its own CDN IPs excluded.
+func (o *originTracker) candidates(host string, target frontedTarget, history map[string][]string, resolversFile string) (candidates []*httpxUtilz.OriginCandidate) {
+	excluded := make(map[string]bool)
+	for _, ip := range target.IPs {
+		excluded[ip] = true
+	}
+	add := func(ips []string, source string) {
+		for _, ip := range ips {
+			if !excluded[ip] {
+				candidates = httpxUtilz.AddOriginCandidate(candidates, ip, source)
+			}
+		}
+	}
+
+	domain := httpxUtilz.GetRegistrableDomain(host)
+	add(history[host], httpxUtilz.OriginSourceDNSHistory)
+	add(httpxUtilz.UniqueStrList(o.siblings[domain]), httpxUtilz.OriginSourceSibling)
+
+	mxIps, spfIps := httpxUtilz.GetMailIPsByDomain(domain, resolversFile)
+	add(mxIps, httpxUtilz.OriginSourceMX)
+	add(spfIps, httpxUtilz.OriginSourceSPF)
+
+	for _, cert := range o.certs {
+		if httpxUtilz.MatchSAN(host, cert.SANs) {
+			add(cert.IPs, httpxUtilz.OriginSourceSAN)
+		}
+	}
+	return
+}
+
+// discover Collect and verify the origin candidates of every fronted target, print them and add them to the buffer.
+func (o *originTracker) discover(params ProcessUrlParams, buffer *bytes.Buffer) {
+	if len(o.fronted) == 0 {
+		return
+	}
+	var history map[string][]string
+	if params.DNSHistory != "" {
+		history = httpxUtilz.LoadDNSHistory(params.DNSHistory)
+	}
+	log.Printf("originTracker> looking for the origin of %d CDN fronted hosts", len(o.fronted))
+
+	var wg sync.WaitGroup
+	var bufferMu sync.Mutex
+	processes := params.Processes
+	if processes < 1 {
+		processes = 1
+	}
+	semaphore := make(chan struct{}, processes)
+
+	for host, target := range o.fronted {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(host string, target frontedTarget) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			candidates := o.candidates(host, target, history, "./data/vaildResolvers.txt")
+			if len(candidates) == 0 {
+				return
+			}
+			config := newRequestConfig(params)
+			info := config.VerifyOriginCandidates(target.Url, candidates)
+			if info == nil || len(info.Candidates) == 0 {
+				return
+			}
+
+			jsonData, err := json.Marshal(OriginResult{OriginInfo: info})
+			if err != nil {
+				log.Println("originTracker> json marshal error:", err)
+				return
+			}
+
+			bufferMu.Lock()
+			defer bufferMu.Unlock()
+			fmt.Println(string(jsonData))
+			buffer.WriteString(string(jsonData))
+			buffer.WriteString("\n")
+		}(host, target)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	httpxUtilz "httpxUtilz/utilz"
+	"log"
+	"strings"
+	"sync"
+)
+
+// vhostCandidates Return the candidate hostnames of -vhosts, or of the input when -vhosts is empty.
+func vhostCandidates(params ProcessUrlParams) (hosts []string) {
+	var inputs []string
+	switch {
+	case params.Vhosts != "":
+		inputs = httpxUtilz.ParseList(params.Vhosts)
+	case len(params.URLPipe) > 0:
+		inputs = params.URLPipe
+	case params.Url != "":
+		inputs = []string{params.Url}
+	case params.Filename != "":
+		inputs, _ = readURLsFromFile(params.Filename)
+	}
+	for _, input := range inputs {
+		host, err := httpxUtilz.GetSubDomain(strings.TrimSpace(input))
+		if err != nil || host == "" {
+			continue
+		}
+		hosts = append(hosts, strings.ToLower(host))
+	}
+	return httpxUtilz.UniqueStrList(hosts)
+}
+
+// ProcessVhosts Send every candidate hostname to the fixed VhostIP and flag those answered differently
+// from a random host of the same domain, by status, body length or title.
+func ProcessVhosts(params ProcessUrlParams) {
+	if err := prepareRun(&params); err != nil {
+		log.Println("ProcessVhosts> ", err)
+		return
+	}
+	defer httpxUtilz.CloseIdleConnections()
+
+	hosts := vhostCandidates(params)
+	if len(hosts) == 0 {
+		log.Println("ProcessVhosts> no candidate hostname")
+		return
+	}
+
+	config := newRequestConfig(params)
+	targetUrl, err := config.DetectUrlScheme(params.VhostIP)
+	if err != nil {
+		log.Println("ProcessVhosts> detect scheme error: ", err)
+		return
+	}
+
+	// One baseline per registrable domain, so that wildcard vhosts of a domain are not all flagged
+	baselines := make(map[string]*httpxUtilz.VhostFingerprint)
+	for _, host := range hosts {
+		domain := httpxUtilz.GetRegistrableDomain(host)
+		if _, ok := baselines[domain]; ok {
+			continue
+		}
+		baseline, err := config.GetVhostBaseline(targetUrl, domain)
+		if err != nil {
+			log.Printf("ProcessVhosts> baseline of %s error: %v", domain, err)
+		}
+		baselines[domain] = baseline
+	}
+
+	var buffer bytes.Buffer
+	var bufferMu sync.Mutex
+	var wg sync.WaitGroup
+
+	processes := params.Processes
+	if processes < 1 {
+		processes = 1
+	}
+	semaphore := make(chan struct{}, processes)
+
+	for _, host := range hosts {
+		baseline := baselines[httpxUtilz.GetRegistrableDomain(host)]
+		if baseline == nil {
+			continue
+		}
+
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(host string, baseline *httpxUtilz.VhostFingerprint) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			// The config headers are written by the client, each goroutine needs its own
+			config := newRequestConfig(params)
+			candidate, err := config.GetVhostFingerprint(targetUrl, host)
+			if err != nil {
+				log.Printf("ProcessVhosts> %s error: %v", host, err)
+				return
+			}
+
+			jsonData, err := json.Marshal(httpxUtilz.GetVhostResult(targetUrl, candidate, baseline))
+			if err != nil {
+				log.Println("ProcessVhosts> json marshal error:", err)
+				return
+			}
+
+			bufferMu.Lock()
+			defer bufferMu.Unlock()
+			fmt.Println(string(jsonData))
+			buffer.WriteString(string(jsonData))
+			buffer.WriteString("\n")
+		}(host, baseline)
+	}
+	wg.Wait()
+
+	if params.Res && buffer.Len() > 0 {
+		if err := WriteBufferToFile(&buffer, params.ResultFile); err != nil {
+			fmt.Println("WriteBufferToFile Error:", err)
+		}
+	}
+}
@@ -3,12 +3,18 @@ module httpxUtilz
 go 1.19
 
 require (
+	github.com/andybalholm/brotli v1.0.5
+	github.com/klauspost/compress v1.16.7
 	github.com/miekg/dns v1.1.55
 	github.com/projectdiscovery/asnmap v1.0.4
 	github.com/projectdiscovery/cdncheck v1.0.9
 	github.com/projectdiscovery/dnsx v1.1.4
+	github.com/projectdiscovery/retryablehttp-go v1.0.18
 	github.com/projectdiscovery/utils v0.0.39
+	github.com/saintfish/chardet v0.0.0-20230101081208-5e3ef4b5456d
 	golang.org/x/net v0.11.0
+	golang.org/x/time v0.3.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -21,8 +27,6 @@ require (
 	github.com/projectdiscovery/blackrock v0.0.1 // indirect
 	github.com/projectdiscovery/mapcidr v1.1.2 // indirect
 	github.com/projectdiscovery/retryabledns v1.0.30 // indirect
-	github.com/projectdiscovery/retryablehttp-go v1.0.18 // indirect
-	github.com/saintfish/chardet v0.0.0-20230101081208-5e3ef4b5456d // indirect
 	github.com/weppos/publicsuffix-go v0.30.0 // indirect
 	github.com/yl2chen/cidranger v1.0.2 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
@@ -30,5 +34,4 @@ require (
 	golang.org/x/sys v0.9.0 // indirect
 	golang.org/x/text v0.10.0 // indirect
 	golang.org/x/tools v0.10.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	asnmap "github.com/projectdiscovery/asnmap/libs"
 	"log"
+	"strings"
 )
 
 type AsnData struct {
@@ -40,7 +41,8 @@ func handleInput(client *asnmap.Client, item string) *AsnData {
 func GetAsnInfoByIps(ips []string, proxy string) (cidr, asn, org, addr []string) {
 	client, err := asnmap.NewClient()
 	if proxy != "" {
-		proxys := []string{proxy}
+		// asnmap doesn't know socks5h, its socks5 dialer already resolves on the proxy side.
+		proxys := []string{strings.Replace(proxy, "socks5h://", "socks5://", 1)}
 		_, err = client.SetProxy(proxys)
 	}
 	if err != nil {
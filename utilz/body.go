@@ -0,0 +1,140 @@
+package utilz
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultMaxBodySize The decoded body size cap used when none is configured, 10 MiB.
+const DefaultMaxBodySize int64 = 10 << 20
+
+// acceptEncoding The content encodings the client decodes itself.
+const acceptEncoding = "gzip, deflate, br, zstd"
+
+// countingReader Count the bytes read from the wire.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// readBody Read the response body, decoding gzip, deflate, br and zstd, without reading more than maxBodySize
+// bytes from the wire nor decoding more than maxBodySize bytes, which protects from decompression bombs.
+func readBody(resp *http.Response, maxBodySize int64) (body []byte, wireSize int64, truncated bool, err error) {
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+
+	wire := &countingReader{reader: io.LimitReader(resp.Body, maxBodySize)}
+
+	var reader io.Reader = wire
+	var decoders []io.Closer
+	defer func() {
+		for _, decoder := range decoders {
+			decoder.Close()
+		}
+	}()
+
+	// Encodings are listed in the order they were applied, undo them in reverse order.
+	encodings := strings.Split(resp.Header.Get("Content-Encoding"), ",")
+decode:
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := strings.ToLower(strings.TrimSpace(encodings[i]))
+		switch encoding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			gzipReader, err := gzip.NewReader(reader)
+			if isEmptyBody(err, wire) {
+				return []byte{}, 0, false, nil
+			}
+			if err != nil {
+				return nil, wire.count, false, fmt.Errorf("readBody> gzip: %w", err)
+			}
+			decoders = append(decoders, gzipReader)
+			reader = gzipReader
+		case "deflate":
+			deflateReader, err := newDeflateReader(reader)
+			if isEmptyBody(err, wire) {
+				return []byte{}, 0, false, nil
+			}
+			if err != nil {
+				return nil, wire.count, false, fmt.Errorf("readBody> deflate: %w", err)
+			}
+			decoders = append(decoders, deflateReader)
+			reader = deflateReader
+		case "br":
+			reader = brotli.NewReader(reader)
+		case "zstd":
+			zstdReader, err := zstd.NewReader(reader, zstd.WithDecoderMaxMemory(uint64(maxBodySize)*2))
+			if err != nil {
+				return nil, wire.count, false, fmt.Errorf("readBody> zstd: %w", err)
+			}
+			decoders = append(decoders, zstdReader.IOReadCloser())
+			reader = zstdReader
+		default:
+			// Unknown encoding, keep the body as decoded so far.
+			break decode
+		}
+	}
+
+	body, err = io.ReadAll(io.LimitReader(reader, maxBodySize+1))
+	if int64(len(body)) > maxBodySize {
+		body = body[:maxBodySize]
+		truncated = true
+	}
+
+	// The wire limit was hit, the body is cut even if the decoder did not notice it.
+	if wire.count >= maxBodySize {
+		var probe [1]byte
+		if n, _ := resp.Body.Read(probe[:]); n > 0 {
+			truncated = true
+		}
+	}
+
+	// HEAD, 204, 304 and redirects often announce an encoding without any body.
+	if isEmptyBody(err, wire) {
+		return []byte{}, 0, false, nil
+	}
+
+	// A cut compressed stream ends with an unexpected EOF, keep what was decoded.
+	if err != nil && (truncated || errors.Is(err, io.ErrUnexpectedEOF)) && len(body) > 0 {
+		truncated = true
+		err = nil
+	}
+
+	return body, wire.count, truncated, err
+}
+
+// isEmptyBody Whether the decoder failed because nothing was received, which is an empty body and not an error.
+func isEmptyBody(err error, wire *countingReader) bool {
+	return err != nil && wire.count == 0 && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF))
+}
+
+// newDeflateReader HTTP deflate is meant to be zlib wrapped, but raw deflate streams are common too.
+func newDeflateReader(reader io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(reader)
+	header, err := buffered.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(buffered)
+	}
+	return flate.NewReader(buffered), nil
+}
@@ -0,0 +1,100 @@
+package utilz
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func newEncodedResponse(t *testing.T, encoding string, data []byte) *http.Response {
+	var buffer bytes.Buffer
+	switch encoding {
+	case "gzip":
+		writer := gzip.NewWriter(&buffer)
+		writer.Write(data)
+		writer.Close()
+	case "deflate":
+		writer := zlib.NewWriter(&buffer)
+		writer.Write(data)
+		writer.Close()
+	case "br":
+		writer := brotli.NewWriter(&buffer)
+		writer.Write(data)
+		writer.Close()
+	case "zstd":
+		writer, err := zstd.NewWriter(&buffer)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		writer.Write(data)
+		writer.Close()
+	default:
+		buffer.Write(data)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Encoding", encoding)
+	return &http.Response{Header: header, Body: ioutil.NopCloser(&buffer)}
+}
+
+func TestReadBody(t *testing.T) {
+	data := bytes.Repeat([]byte("<html>httpxUtilz</html>"), 100)
+
+	for _, encoding := range []string{"", "gzip", "deflate", "br", "zstd"} {
+		body, wireSize, truncated, err := readBody(newEncodedResponse(t, encoding, data), 1<<20)
+		if err != nil {
+			t.Errorf("Unexpected error for encoding '%s': %v", encoding, err)
+			continue
+		}
+		if !bytes.Equal(body, data) || truncated {
+			t.Errorf("Expected the decoded body for encoding '%s', got %d bytes (truncated %v)", encoding, len(body), truncated)
+		}
+		if encoding != "" && wireSize >= int64(len(data)) {
+			t.Errorf("Expected the wire size for encoding '%s' to be smaller than %d, but got %d", encoding, len(data), wireSize)
+		}
+	}
+}
+
+func TestReadBodyEmpty(t *testing.T) {
+	// HEAD, 204 and 304 responses announce the encoding of a body they don't send
+	for _, encoding := range []string{"", "gzip", "x-gzip", "deflate", "br", "zstd", "gzip, br"} {
+		header := http.Header{}
+		header.Set("Content-Encoding", encoding)
+		resp := &http.Response{Header: header, Body: ioutil.NopCloser(bytes.NewReader(nil))}
+
+		body, wireSize, truncated, err := readBody(resp, 1<<20)
+		if err != nil {
+			t.Errorf("Unexpected error for encoding '%s': %v", encoding, err)
+			continue
+		}
+		if len(body) != 0 || wireSize != 0 || truncated {
+			t.Errorf("Expected an empty body for encoding '%s', got %d bytes (wire %d, truncated %v)", encoding, len(body), wireSize, truncated)
+		}
+	}
+
+	// A cut stream is still an error
+	resp := &http.Response{Header: http.Header{"Content-Encoding": {"gzip"}}, Body: ioutil.NopCloser(bytes.NewReader([]byte{0x1f}))}
+	if _, _, _, err := readBody(resp, 1<<20); err == nil {
+		t.Error("Expected an error for a cut gzip header")
+	}
+}
+
+func TestReadBodyDecompressionBomb(t *testing.T) {
+	// 64 MiB of zeros compress to a few KiB
+	bomb := make([]byte, 64<<20)
+	maxBodySize := int64(1 << 20)
+
+	body, _, truncated, err := readBody(newEncodedResponse(t, "gzip", bomb), maxBodySize)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !truncated || int64(len(body)) != maxBodySize {
+		t.Errorf("Expected the body to be truncated to %d bytes, got %d bytes (truncated %v)", maxBodySize, len(body), truncated)
+	}
+}
@@ -0,0 +1,92 @@
+package utilz
+
+import (
+	"bytes"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/saintfish/chardet"
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	reMetaCharset = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_:.\-]+)`)
+	// metaPrescanSize The number of bytes searched for a <meta charset>, as browsers do.
+	metaPrescanSize = 4096
+)
+
+// DetectCharset Return the charset of the body from the Content-Type header, then <meta charset>, then byte sniffing.
+// An empty charset is returned for binary content.
+func DetectCharset(data []byte, contentType string) string {
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	if name := strings.TrimSpace(params["charset"]); name != "" {
+		return strings.ToLower(name)
+	}
+
+	if !isTextMediaType(mediaType) {
+		return ""
+	}
+
+	if bytes.HasPrefix(data, []byte("\xef\xbb\xbf")) {
+		return "utf-8"
+	}
+
+	prescan := data
+	if len(prescan) > metaPrescanSize {
+		prescan = prescan[:metaPrescanSize]
+	}
+	if match := reMetaCharset.FindSubmatch(prescan); match != nil {
+		return strings.ToLower(string(match[1]))
+	}
+
+	if utf8.Valid(data) {
+		return "utf-8"
+	}
+
+	result, err := chardet.NewHtmlDetector().DetectBest(data)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(result.Charset)
+}
+
+func isTextMediaType(mediaType string) bool {
+	if mediaType == "" || strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	for _, textual := range []string{"xml", "json", "javascript", "ecmascript", "x-www-form-urlencoded"} {
+		if strings.Contains(mediaType, textual) {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeBody Transcode the body to UTF-8 and return it with the detected charset.
+// The body is returned unchanged when the charset is unknown, binary or already UTF-8.
+func DecodeBody(data []byte, contentType string) ([]byte, string) {
+	charsetName := DetectCharset(data, contentType)
+	if charsetName == "" {
+		return data, ""
+	}
+
+	encoding, name := charset.Lookup(charsetName)
+	if encoding == nil {
+		// chardet names some charsets differently from the WHATWG labels, e.g. GB-18030
+		encoding, name = charset.Lookup(strings.ReplaceAll(charsetName, "-", ""))
+	}
+	if encoding == nil {
+		return data, charsetName
+	}
+	if name == "utf-8" {
+		return data, name
+	}
+
+	decoded, err := encoding.NewDecoder().Bytes(data)
+	if err != nil {
+		return data, charsetName
+	}
+	return decoded, name
+}
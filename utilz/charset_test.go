@@ -0,0 +1,59 @@
+package utilz
+
+import (
+	"bytes"
+	"testing"
+)
+
+var (
+	// "中文网页" in GBK, "日本語のページ" in Shift-JIS and "Привет, мир" in Windows-1251
+	gbkText    = []byte("\xd6\xd0\xce\xc4\xcd\xf8\xd2\xb3")
+	sjisText   = []byte("\x93\xfa\x96\x7b\x8c\xea\x82\xcc\x83\x79\x81\x5b\x83\x57")
+	cp1251Text = []byte("\xcf\xf0\xe8\xe2\xe5\xf2, \xec\xe8\xf0")
+)
+
+func TestDetectCharset(t *testing.T) {
+	metaGBK := []byte(`<html><head><meta charset="gbk"></head><body>` + string(gbkText) + `</body></html>`)
+	for _, test := range []struct {
+		name        string
+		data        []byte
+		contentType string
+		want        string
+	}{
+		{"header before meta", metaGBK, "text/html; charset=Shift_JIS", "shift_jis"},
+		{"meta", metaGBK, "text/html", "gbk"},
+		{"http-equiv meta", []byte(`<meta http-equiv="Content-Type" content="text/html; charset=windows-1251">`), "text/html", "windows-1251"},
+		{"bom before meta", append([]byte("\xef\xbb\xbf"), metaGBK...), "text/html", "utf-8"},
+		{"header before bom", append([]byte("\xef\xbb\xbf"), "abc"...), "text/plain; charset=ISO-8859-1", "iso-8859-1"},
+		{"meta after the prescan", append(bytes.Repeat([]byte(" "), metaPrescanSize), `<meta charset="gbk">`...), "text/html", "utf-8"},
+		{"valid utf-8", []byte("<p>中文</p>"), "", "utf-8"},
+		{"binary", []byte("\x89PNG\r\n\x1a\n"), "image/png", ""},
+	} {
+		if got := DetectCharset(test.data, test.contentType); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		data        []byte
+		contentType string
+		want        string
+		charset     string
+	}{
+		{"gbk header", gbkText, "text/html; charset=GBK", "中文网页", "gbk"},
+		{"gb2312 meta", append([]byte(`<meta charset="gb2312">`), gbkText...), "text/html", `<meta charset="gb2312">中文网页`, "gbk"},
+		{"shift-jis header", sjisText, "text/html; charset=Shift_JIS", "日本語のページ", "shift_jis"},
+		{"windows-1251 meta", append([]byte(`<meta charset="windows-1251">`), cp1251Text...), "text/html", `<meta charset="windows-1251">Привет, мир`, "windows-1251"},
+		{"utf-8", []byte("中文"), "text/html; charset=utf-8", "中文", "utf-8"},
+		{"unknown charset", []byte("abc"), "text/html; charset=x-unknown", "abc", "x-unknown"},
+		{"binary", []byte("\x89PNG"), "image/png", "\x89PNG", ""},
+	} {
+		decoded, charsetName := DecodeBody(test.data, test.contentType)
+		if string(decoded) != test.want || charsetName != test.charset {
+			t.Errorf("%s: got %q (%s), want %q (%s)", test.name, decoded, charsetName, test.want, test.charset)
+		}
+	}
+}
@@ -32,12 +32,17 @@ func UniqueStrList(strList []string) []string {
 }
 
 func DnsxClient(domain string, resolversFile string) *dnsx.DNSX {
+	return dnsxClientByTypes(resolversFile, dns.TypeA)
+}
+
+// dnsxClientByTypes Create a dnsx client asking the question types, QueryMultiple asks them all.
+func dnsxClientByTypes(resolversFile string, questionTypes ...uint16) *dnsx.DNSX {
 	validResolversList := UniqueStrList(FileContentToList(resolversFile))
 
 	DefaultOptions := dnsx.Options{
 		BaseResolvers:     validResolversList,
 		MaxRetries:        5,
-		QuestionTypes:     []uint16{dns.TypeA},
+		QuestionTypes:     questionTypes,
 		TraceMaxRecursion: math.MaxUint16,
 		Hostsfile:         true,
 	}
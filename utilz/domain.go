@@ -3,6 +3,7 @@ package utilz
 import (
 	"errors"
 	"log"
+	"net"
 	"strings"
 )
 
@@ -33,3 +34,42 @@ func GetSubDomain(url string) (domain string, err error) {
 
 	return domain, nil
 }
+
+// GetScopeByUrls Return the registrable domains of the urls, used as the default scope.
+func GetScopeByUrls(urls []string) (scope []string) {
+	for _, url := range urls {
+		host, err := GetSubDomain(url)
+		if err != nil || host == "" {
+			continue
+		}
+		scope = append(scope, GetRegistrableDomain(strings.ToLower(host)))
+	}
+	return UniqueStrList(scope)
+}
+
+// InScope Report whether the host is one of the scope domains or a subdomain of one.
+func InScope(host string, scope []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, domain := range scope {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain == "" {
+			continue
+		}
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetHostsBySANs Return the hostnames of certificate SANs, wildcards are reduced to their parent domain and IPs dropped.
+func GetHostsBySANs(sans []string) (hosts []string) {
+	for _, san := range sans {
+		host := strings.ToLower(strings.TrimPrefix(san, "*."))
+		if host == "" || net.ParseIP(host) != nil || strings.ContainsAny(host, "*@ ") {
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+	return UniqueStrList(hosts)
+}
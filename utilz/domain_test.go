@@ -0,0 +1,65 @@
+package utilz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetScopeByUrls(t *testing.T) {
+	scope := GetScopeByUrls([]string{
+		"https://www.Example.com/login",
+		"http://api.example.com:8080",
+		"shop.example.co.uk",
+		"http://10.0.0.1:8000/",
+		"",
+	})
+	want := []string{"example.com", "example.co.uk", "10.0.0.1"}
+	if strings.Join(scope, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", scope, want)
+	}
+}
+
+func TestInScope(t *testing.T) {
+	scope := []string{"example.com", " Example.co.uk ", ""}
+	for host, want := range map[string]bool{
+		"example.com":         true,
+		"a.b.example.com":     true,
+		"WWW.EXAMPLE.COM.":    true,
+		"shop.example.co.uk":  true,
+		"notexample.com":      false,
+		"example.com.evil.io": false,
+		"co.uk":               false,
+		"":                    false,
+	} {
+		if got := InScope(host, scope); got != want {
+			t.Errorf("%q: got %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestGetHostsBySANs(t *testing.T) {
+	hosts := GetHostsBySANs([]string{"*.Example.com", "example.com", "api.example.com", "10.0.0.1", "::1", "admin@example.com", "*", ""})
+	if strings.Join(hosts, ",") != "example.com,api.example.com" {
+		t.Errorf("got %v", hosts)
+	}
+
+	// The SANs of a live certificate: the httptest one holds example.com and loopback IPs
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	config := &RequestClientConfig{Headers: map[string]string{}, Timeout: 5, UseHTTPS: true}
+	resp, err := config.GetResponseByUrl(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts = GetHostsBySANs(resp.TLS.SANs)
+	if len(hosts) == 0 || !InScope(hosts[0], []string{"example.com"}) {
+		t.Errorf("got hosts %v from SANs %v", hosts, resp.TLS.SANs)
+	}
+	for _, host := range hosts {
+		if strings.Contains(host, ":") || strings.HasPrefix(host, "127.") {
+			t.Errorf("IP SAN %s kept", host)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package utilz
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// faviconCache Favicon hash by favicon url, so that paths and ports of the same site fetch it once.
+var faviconCache sync.Map
+
+// ExtractFaviconUrl Return the icon url declared by <link rel=icon>, or /favicon.ico of the page host.
+func ExtractFaviconUrl(r *Response, pageUrl string) string {
+	base, err := url.Parse(pageUrl)
+	if err != nil {
+		return ""
+	}
+
+	var href string
+	var crawler func(*html.Node)
+	crawler = func(node *html.Node) {
+		if node.Type == html.ElementNode && node.Data == "link" {
+			var rel, link string
+			for _, attr := range node.Attr {
+				switch strings.ToLower(attr.Key) {
+				case "rel":
+					rel = strings.ToLower(attr.Val)
+				case "href":
+					link = strings.TrimSpace(attr.Val)
+				}
+			}
+			for _, value := range strings.Fields(rel) {
+				if value == "icon" && link != "" {
+					href = link
+					return
+				}
+			}
+		}
+		for child := node.FirstChild; child != nil && href == ""; child = child.NextSibling {
+			crawler(child)
+		}
+	}
+	if htmlDoc, err := r.Document(); err == nil {
+		crawler(htmlDoc)
+	}
+
+	if href == "" || strings.HasPrefix(href, "data:") {
+		href = "/favicon.ico"
+	}
+	faviconUrl, err := base.Parse(href)
+	if err != nil {
+		return ""
+	}
+	return faviconUrl.String()
+}
+
+// GetFaviconHashByUrl Fetch the favicon and return its Shodan compatible mmh3 hash, empty if there is no icon.
+func (config *RequestClientConfig) GetFaviconHashByUrl(faviconUrl string) string {
+	if faviconUrl == "" {
+		return ""
+	}
+	if hash, ok := faviconCache.Load(faviconUrl); ok {
+		return hash.(string)
+	}
+
+	// The icon is always fetched with GET whatever the probe method is.
+	faviconConfig := *config
+	faviconConfig.Method = http.MethodGet
+
+	var hash string
+	resp, err := faviconConfig.GetResponseByUrl(faviconUrl)
+	if err == nil && resp.Status == http.StatusOK && len(resp.Data) > 0 {
+		hash = strconv.Itoa(int(GetFaviconHash(resp.Data)))
+	}
+	faviconCache.Store(faviconUrl, hash)
+
+	return hash
+}
@@ -0,0 +1,116 @@
+package utilz
+
+import (
+	"log"
+	"strings"
+)
+
+// HaE engines, Java's backtracking regex (nfa) accepts syntax RE2 doesn't, like lookarounds.
+const (
+	haeEngineNFA = "nfa"
+	haeEngineDFA = "dfa"
+)
+
+// haeSeverities HaE highlight colors, from the most to the least important.
+var haeSeverities = map[string]string{
+	"red":     "high",
+	"orange":  "medium",
+	"yellow":  "low",
+	"green":   "info",
+	"cyan":    "info",
+	"blue":    "info",
+	"pink":    "info",
+	"magenta": "info",
+	"gray":    "info",
+}
+
+// haeRuleFile HaE's Rules.yml: rules grouped under a name.
+type haeRuleFile struct {
+	Rules []struct {
+		Group string    `yaml:"group"`
+		Rule  []haeRule `yaml:"rule"`
+	} `yaml:"rules"`
+}
+
+// haeRule A HaE rule, Regex is the single pattern of the rules files older than f_regex.
+type haeRule struct {
+	Name      string `yaml:"name"`
+	Loaded    bool   `yaml:"loaded"`
+	FRegex    string `yaml:"f_regex"`
+	SRegex    string `yaml:"s_regex"`
+	Regex     string `yaml:"regex"`
+	Color     string `yaml:"color"`
+	Scope     string `yaml:"scope"`
+	Engine    string `yaml:"engine"`
+	Sensitive bool   `yaml:"sensitive"`
+}
+
+// isHaE Whether the file is grouped like HaE's, the structured format has no groups.
+func (f haeRuleFile) isHaE() bool {
+	for _, group := range f.Rules {
+		if group.Group != "" || len(group.Rule) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// getHaEScope Map a HaE scope on the response scopes, false for the request and the status line.
+func getHaEScope(scope string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(scope)) {
+	case "any", "response":
+		return RuleScopeResponse, true
+	case "any header", "response header":
+		return RuleScopeHeaders, true
+	case "any body", "response body":
+		return RuleScopeBody, true
+	}
+	return "", false
+}
+
+// haeMatchRules Turn the loaded HaE rules into match rules. The value is the first group of f_regex, like
+// HaE's default "{0}" format, refined by s_regex when set; the group name becomes a tag.
+func haeMatchRules(file haeRuleFile) []*MatchRule {
+	var rules []*MatchRule
+	for _, group := range file.Rules {
+		for _, hae := range group.Rule {
+			if !hae.Loaded {
+				continue
+			}
+			scope, ok := getHaEScope(hae.Scope)
+			if !ok {
+				log.Printf("LoadMatchRules> skip HaE rule %s: scope %q isn't in the response", hae.Name, hae.Scope)
+				continue
+			}
+			pattern := hae.FRegex
+			if pattern == "" {
+				pattern = hae.Regex
+			}
+			severity, ok := haeSeverities[strings.ToLower(hae.Color)]
+			if !ok {
+				severity = "info"
+			}
+			engine := strings.ToLower(hae.Engine)
+			if engine != haeEngineDFA {
+				engine = haeEngineNFA
+			}
+
+			rule := &MatchRule{
+				Name:             hae.Name,
+				Severity:         severity,
+				Scope:            scope,
+				Patterns:         []string{pattern},
+				CaseSensitive:    hae.Sensitive,
+				firstGroup:       true,
+				secondaryPattern: hae.SRegex,
+				engine:           engine,
+				sensitive:        true,
+			}
+			if group.Group != "" {
+				rule.Tags = []string{group.Group}
+			}
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
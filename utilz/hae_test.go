@@ -0,0 +1,96 @@
+package utilz
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLoadHaERules(t *testing.T) {
+	dir := t.TempDir()
+	rulesFile := writeTestFile(t, dir, "Rules.yml", `rules:
+- group: Fingerprint
+  rule:
+  - name: Shiro
+    loaded: true
+    f_regex: (=deleteMe|rememberMe=)
+    s_regex: ''
+    format: '{0}'
+    color: green
+    scope: any header
+    engine: dfa
+    sensitive: true
+- group: Sensitive Information
+  rule:
+  - name: Password Field
+    loaded: true
+    f_regex: ((?:password|pwd)\s*[:=]\s*"[^"]+")
+    s_regex: '"([^"]+)"'
+    format: '{0}'
+    color: red
+    scope: response body
+    engine: nfa
+    sensitive: false
+  - name: Lookahead
+    loaded: true
+    f_regex: (token(?=[0-9]+))
+    s_regex: ''
+    format: '{0}'
+    color: orange
+    scope: response
+    engine: nfa
+    sensitive: false
+  - name: Disabled
+    loaded: false
+    f_regex: (secret)
+    color: red
+    scope: response body
+    engine: dfa
+    sensitive: false
+  - name: Request Cookie
+    loaded: true
+    f_regex: (session=\w+)
+    color: yellow
+    scope: request header
+    engine: dfa
+    sensitive: false
+`)
+
+	rules, err := LoadMatchRules(rulesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The disabled, request and lookahead rules are skipped
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d", len(rules))
+	}
+
+	resp := &Response{
+		Raw:     `PASSWORD: "hunter2", pwd="s3cret" secret`,
+		Headers: http.Header{"Set-Cookie": {"rememberMe=deleteMe; Path=/"}},
+	}
+	matches := findingsByName(MatchResponseWithRules(resp, rules))
+
+	shiro := matches["Shiro"]
+	if len(shiro) != 1 || shiro[0].Severity != "info" || shiro[0].Scope != RuleScopeHeaders || shiro[0].Tags[0] != "Fingerprint" {
+		t.Errorf("Shiro: %+v", shiro)
+	}
+	// s_regex refines the f_regex value, located in the body
+	password := matches["Password Field"]
+	if len(password) != 2 || password[0].Severity != "high" || password[0].Match != "hunter2" || password[0].Offset != 11 || password[1].Match != "s3cret" {
+		t.Errorf("Password Field: %+v", password)
+	}
+	for _, match := range RedactMatches(password) {
+		if !match.Redacted || strings.Contains(match.Snippet, "hunter2") {
+			t.Errorf("HaE findings should be redacted: %+v", match)
+		}
+	}
+	if _, ok := matches["Disabled"]; ok {
+		t.Error("Disabled should not be loaded")
+	}
+
+	// The grouped file goes through MatchResponseWithJSONRules like the JSON rules
+	if got := MatchResponseWithJSONRules(resp.Raw, rulesFile); got["Password Field"] != "hunter2" {
+		t.Errorf("MatchResponseWithJSONRules: %v", got)
+	}
+}
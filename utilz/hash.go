@@ -0,0 +1,114 @@
+package utilz
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// Mmh3Hash32 MurmurHash3 x86 32-bit with seed 0, signed like the python mmh3 package.
+func Mmh3Hash32(data []byte) int32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+	var h1 uint32
+
+	nblocks := len(data) / 4
+	for i := 0; i < nblocks; i++ {
+		k1 := binary.LittleEndian.Uint32(data[i*4:])
+		k1 *= c1
+		k1 = bits.RotateLeft32(k1, 15)
+		k1 *= c2
+
+		h1 ^= k1
+		h1 = bits.RotateLeft32(h1, 13)
+		h1 = h1*5 + 0xe6546b64
+	}
+
+	tail := data[nblocks*4:]
+	var k1 uint32
+	switch len(tail) {
+	case 3:
+		k1 ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k1 ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k1 ^= uint32(tail[0])
+		k1 *= c1
+		k1 = bits.RotateLeft32(k1, 15)
+		k1 *= c2
+		h1 ^= k1
+	}
+
+	h1 ^= uint32(len(data))
+	h1 ^= h1 >> 16
+	h1 *= 0x85ebca6b
+	h1 ^= h1 >> 13
+	h1 *= 0xc2b2ae35
+	h1 ^= h1 >> 16
+
+	return int32(h1)
+}
+
+// GetFaviconHash Return the Shodan compatible favicon hash, mmh3 of the base64 encoded icon
+// wrapped every 76 characters with a trailing newline (python base64.encodebytes).
+func GetFaviconHash(data []byte) int32 {
+	encoded := base64.StdEncoding.EncodeToString(data)
+
+	var builder strings.Builder
+	for len(encoded) > 76 {
+		builder.WriteString(encoded[:76])
+		builder.WriteByte('\n')
+		encoded = encoded[76:]
+	}
+	builder.WriteString(encoded)
+	builder.WriteByte('\n')
+
+	return Mmh3Hash32([]byte(builder.String()))
+}
+
+// GetBodySha256 Return the hex encoded SHA-256 of the body.
+func GetBodySha256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// GetBodySimhash Return the 64-bit simhash of the body words, similar pages get hashes with a small hamming distance.
+func GetBodySimhash(data []byte) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(string(data)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return 0
+	}
+
+	var weights [64]int
+	for _, word := range words {
+		hasher := fnv.New64a()
+		hasher.Write([]byte(word)) //nolint
+		feature := hasher.Sum64()
+		for i := 0; i < 64; i++ {
+			if feature&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+
+	var simhash uint64
+	for i := 0; i < 64; i++ {
+		if weights[i] > 0 {
+			simhash |= 1 << uint(i)
+		}
+	}
+	return simhash
+}
@@ -0,0 +1,31 @@
+package utilz
+
+import "testing"
+
+func TestMmh3Hash32(t *testing.T) {
+	// Expected values computed with the python mmh3 package
+	expectedHashes := map[string]int32{
+		"":      0,
+		"hello": 613153351,
+		"foo":   -156908512,
+	}
+
+	for data, expectedHash := range expectedHashes {
+		if hash := Mmh3Hash32([]byte(data)); hash != expectedHash {
+			t.Errorf("Expected hash %d for '%s', but got %d", expectedHash, data, hash)
+		}
+	}
+}
+
+func TestGetBodySimhash(t *testing.T) {
+	page := []byte("<html><title>Login</title><body>Welcome to the admin console, please sign in</body></html>")
+	similarPage := []byte("<html><title>Login</title><body>Welcome to the admin console, please log in</body></html>")
+
+	distance := 0
+	for diff := GetBodySimhash(page) ^ GetBodySimhash(similarPage); diff != 0; diff &= diff - 1 {
+		distance++
+	}
+	if distance > 16 {
+		t.Errorf("Expected similar pages to have a small hamming distance, but got %d", distance)
+	}
+}
@@ -1,10 +1,16 @@
 package utilz
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
 )
 
 type Response struct {
@@ -14,6 +20,28 @@ type Response struct {
 	Status                 int
 	ContentLength          int64
 	ContentLengthByAllBody int64
+	ContentLengthByWire    int64
+	Truncated              bool
+	FinalUrl               string
+	RedirectChain          []RedirectHop
+	TLS                    *TLSInfo
+	Charset                string
+	Timing                 *Timing
+	RemoteIP               string
+	Attempts               int
+
+	document    *html.Node
+	documentErr error
+	parsed      bool
+}
+
+// Document Return the parsed HTML document of the body, it is parsed once and shared by all extractors.
+func (r *Response) Document() (*html.Node, error) {
+	if !r.parsed {
+		r.document, r.documentErr = html.Parse(strings.NewReader(r.Raw))
+		r.parsed = true
+	}
+	return r.document, r.documentErr
 }
 
 func (config *RequestClientConfig) GetResponseByUrl(targetUrl string) (*Response, error) {
@@ -22,27 +50,120 @@ func (config *RequestClientConfig) GetResponseByUrl(targetUrl string) (*Response
 		log.Println("GetResponseByUrl: ", err)
 		return nil, err
 	}
-	client := NewRequestClient(*config)
-	resp, err := client.Get(target)
+	// The client records every followed redirect into the chain carried by the context.
+	var redirectChain []RedirectHop
+	ctx := context.WithValue(context.Background(), redirectChainKey{}, &redirectChain)
+
+	// Instrument the request to report the latency of each phase and the IP which answered.
+	trace := newTimingTrace()
+	ctx = httptrace.WithClientTrace(ctx, trace.clientTrace())
+
+	method := config.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var reqBody io.Reader
+	if config.Body != "" {
+		reqBody = strings.NewReader(config.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reqBody)
+	if err != nil {
+		log.Println("GetResponseByUrl: ", err)
+		return nil, err
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if config.Host != "" {
+		req.Host = config.Host
+	}
+	for key, value := range config.Headers {
+		if value != "" {
+			req.Header.Set(key, value)
+		}
+	}
+	if config.RandomUserAgent || req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", getRandomUserAgent())
+	}
+
+	var resp *http.Response
+	attempts := 0
+	for {
+		attempts++
+		redirectChain = redirectChain[:0]
+		// The body was sent by the previous attempt
+		if attempts > 1 && req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				break
+			}
+		}
+
+		// Each attempt may go through another proxy of the pool, redirects stay on the same one.
+		attemptConfig := *config
+		if attemptConfig.ProxyURL, err = config.GetProxyURL(); err != nil {
+			break
+		}
+		var client *http.Client
+		if client, err = NewRequestClient(attemptConfig); err != nil {
+			break
+		}
+		if config.Host != "" {
+			// The transport of a Host override isn't pooled, release it with the response
+			defer client.CloseIdleConnections()
+		}
+
+		if err = config.RateLimiter.Wait(ctx, req.URL.Hostname()); err != nil {
+			break
+		}
+		resp, err = client.Do(req)
+		config.RateLimiter.Observe(req.URL.Hostname(), resp, err)
+		config.ProxyPool.Observe(attemptConfig.ProxyURL, err)
+		if attempts > config.Retries || !shouldRetry(ctx, resp, err, config.RetryOnStatus) {
+			break
+		}
+
+		wait := getRetryWait(attempts, resp)
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096)) //nolint
+			resp.Body.Close()
+		}
+		log.Printf("GetResponseByUrl: retry %s in %s (attempt %d/%d)", target, wait, attempts, config.Retries+1)
+		time.Sleep(wait)
+	}
 	if err != nil {
 		log.Println("GetResponseByUrl: ", err)
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	// The last response is part of the chain too, with its Location when the redirect was not followed.
+	recordRedirectHop(ctx, resp)
+
+	body, wireSize, truncated, err := readBody(resp, config.MaxBodySize)
 	if err != nil {
 		log.Println("GetResponseByUrl: ", err)
 		return nil, err
 	}
 
+	timing, remoteIP := trace.timing()
+
+	// Title extraction and regex matching work on the body transcoded to UTF-8.
+	text, charsetName := DecodeBody(body, resp.Header.Get("Content-Type"))
+
 	return &Response{
-		Raw:                    string(body),
+		Raw:                    string(text),
 		Data:                   body,
 		Headers:                resp.Header,
 		Status:                 resp.StatusCode,
 		ContentLength:          resp.ContentLength,
 		ContentLengthByAllBody: int64(len(body)),
+		ContentLengthByWire:    wireSize,
+		Truncated:              truncated,
+		FinalUrl:               resp.Request.URL.String(),
+		RedirectChain:          redirectChain,
+		TLS:                    config.getTLSInfo(resp),
+		Charset:                charsetName,
+		Timing:                 timing,
+		RemoteIP:               remoteIP,
+		Attempts:               attempts,
 	}, nil
 }
 
@@ -112,6 +233,74 @@ func (config *RequestClientConfig) GetContentLengthAllBodyByResponse(resp *Respo
 	return
 }
 
+func (config *RequestClientConfig) GetContentLengthWireByResponse(resp *Response) (contentLengthByWire int64, truncated bool) {
+	contentLengthByWire = resp.ContentLengthByWire
+	truncated = resp.Truncated
+	return
+}
+
+func (config *RequestClientConfig) GetTimingByResponse(resp *Response) (timing *Timing, remoteIP string) {
+	timing = resp.Timing
+	remoteIP = resp.RemoteIP
+	return
+}
+
+func (config *RequestClientConfig) GetAttemptsByResponse(resp *Response) (attempts int) {
+	attempts = resp.Attempts
+	return
+}
+
+func (config *RequestClientConfig) GetCharsetByResponse(resp *Response) (charsetName string) {
+	charsetName = resp.Charset
+	return
+}
+
+func (config *RequestClientConfig) GetFinalUrlByResponse(resp *Response) (finalUrl string) {
+	finalUrl = resp.FinalUrl
+	return
+}
+
+func (config *RequestClientConfig) GetRedirectChainByResponse(resp *Response) (redirectChain []RedirectHop) {
+	redirectChain = resp.RedirectChain
+	return
+}
+
+// getTLSInfo Return the TLS details of the response, verified as the configured client would.
+func (config *RequestClientConfig) getTLSInfo(resp *http.Response) *TLSInfo {
+	host := resp.Request.URL.Hostname()
+	if serverName := config.getTLSOptions().ServerName; serverName != "" {
+		host = serverName
+	}
+	roots, _ := loadCAPool(config.TLS.CAFile)
+	return GetTLSInfo(resp.TLS, host, roots)
+}
+
+func (config *RequestClientConfig) GetTLSInfoByResponse(resp *Response) (tlsInfo *TLSInfo) {
+	tlsInfo = resp.TLS
+	return
+}
+
+func (config *RequestClientConfig) GetPageInfoByResponse(resp *Response) (pageInfo *PageInfo) {
+	pageInfo = ExtractPageInfo(resp)
+	return
+}
+
+func (config *RequestClientConfig) GetFaviconHashByResponse(resp *Response) (faviconHash string) {
+	faviconHash = config.GetFaviconHashByUrl(ExtractFaviconUrl(resp, resp.FinalUrl))
+	return
+}
+
+func (config *RequestClientConfig) GetBodyHashByResponse(resp *Response) (bodySha256 string, bodySimhash uint64) {
+	bodySha256 = GetBodySha256(resp.Data)
+	bodySimhash = GetBodySimhash([]byte(resp.Raw))
+	return
+}
+
+func (config *RequestClientConfig) GetTechnologiesByResponse(resp *Response, rulesFile string) (technologies []Technology) {
+	technologies = DetectTechnologies(resp, rulesFile)
+	return
+}
+
 func (config *RequestClientConfig) GetCNameIPByDomain(domain string, resolversFile string) (cname, ips []string) {
 	cname, ips = GetCnameIPsByDomain(domain, resolversFile)
 	if len(cname) == 0 {
@@ -143,13 +332,40 @@ func (config *RequestClientConfig) GetCdnInfoByAll(resp *Response, ips, cidr, as
 	return
 }
 
+func (config *RequestClientConfig) GetWafInfoByAll(resp *Response, targetUrl string, probe bool, wafSignaturesFile string) (wafInfo *WafInfo) {
+	wafInfo = &WafInfo{}
+	wafInfo.Vendor, wafInfo.Evidence = DetectWafByResponse(resp, wafSignaturesFile)
+
+	if probe {
+		wafInfo.Probed = true
+		blocked, vendor, evidence := config.GetWafInfoByProbe(targetUrl, resp, wafSignaturesFile)
+		if blocked {
+			if wafInfo.Vendor == "" {
+				wafInfo.Vendor = vendor
+			}
+			if wafInfo.Vendor == "" {
+				wafInfo.Vendor = "unknown"
+			}
+			wafInfo.Evidence = append(wafInfo.Evidence, evidence...)
+		}
+	}
+
+	wafInfo.Detected = wafInfo.Vendor != ""
+	return
+}
+
 func (config *RequestClientConfig) GetAsnInfoByIp(ips []string, proxy string) (cidr, asn, org, addr []string) {
 	cidr, asn, org, addr = GetAsnInfoByIps(ips, proxy)
 	return
 }
 
-func (config *RequestClientConfig) GetMayVulInfoByRespone(resp *Response, rulesFiles string) (vulMatches map[string]string) {
-	vulMatches = MatchResponseWithJSONRules(resp.Raw, rulesFiles)
+func (config *RequestClientConfig) GetMayVulInfoByRespone(resp *Response, rulesFiles string, allowlist *Allowlist) (vulMatches []RuleMatch) {
+	rules, err := LoadMatchRules(rulesFiles)
+	if err != nil {
+		log.Println("GetMayVulInfoByRespone> ", err)
+		return
+	}
+	vulMatches = allowlist.Filter(resp.FinalUrl, MatchResponseWithRules(resp, rules))
 
 	return
 }
@@ -1,35 +1,548 @@
 package utilz
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
 )
 
-func MatchResponseWithJSONRules(response string, rulesFiles string) (matches map[string]string) {
-	var rules map[string]string
+// Rule scopes, the part of the response a rule is searched in.
+const (
+	RuleScopeBody    = "body"
+	RuleScopeHeaders = "headers"
+	RuleScopeHeader  = "header"
+	RuleScopeUrl     = "url"
+	RuleScopeTitle   = "title"
+	// RuleScopeResponse The headers then the body.
+	RuleScopeResponse = "response"
+)
+
+var (
+	ruleSeverities   = map[string]bool{"info": true, "low": true, "medium": true, "high": true, "critical": true}
+	ruleConfidences  = map[string]bool{"low": true, "medium": true, "high": true}
+	ruleSeverityRank = map[string]int{"critical": 0, "high": 1, "medium": 2, "low": 3, "info": 4}
+	reRuleIdUnsafe   = regexp.MustCompile(`[^a-z0-9]+`)
+)
 
-	jsonRules, err := ioutil.ReadFile(rulesFiles)
+const (
+	// defaultRuleMaxMatches Distinct values reported per rule and response when the rule sets no cap.
+	defaultRuleMaxMatches = 20
+	// ruleSnippetContext Bytes of context kept on each side of a match.
+	ruleSnippetContext = 40
+)
+
+// MatchRule A may vul rule: patterns searched in one scope of the response. The rule hits when its patterns
+// find at least MinMatches distinct values and no negative pattern matches the same scope, at most
+// MaxMatches values are reported. Secret rules only keep values, or their "secret" group, with an entropy
+// of at least Entropy bits per character and accepted by the Validator.
+type MatchRule struct {
+	ID               string   `json:"id" yaml:"id"`
+	Name             string   `json:"name" yaml:"name"`
+	Severity         string   `json:"severity" yaml:"severity"`
+	Confidence       string   `json:"confidence" yaml:"confidence"`
+	Tags             []string `json:"tags" yaml:"tags"`
+	Scope            string   `json:"scope" yaml:"scope"`
+	Header           string   `json:"header" yaml:"header"`
+	Patterns         []string `json:"patterns" yaml:"patterns"`
+	NegativePatterns []string `json:"negative_patterns" yaml:"negative_patterns"`
+	MinMatches       int      `json:"min_matches" yaml:"min_matches"`
+	MaxMatches       int      `json:"max_matches" yaml:"max_matches"`
+	CaseSensitive    bool     `json:"case_sensitive" yaml:"case_sensitive"`
+	Entropy          float64  `json:"entropy" yaml:"entropy"`
+	Validator        string   `json:"validator" yaml:"validator"`
+
+	patterns  []*regexp.Regexp
+	negatives []*regexp.Regexp
+	validate  func(string) bool
+	// Set for the legacy and HaE rules, which may find secrets without saying so.
+	sensitive bool
+
+	// Set by the HaE loader: values are the first group of the patterns, refined by the secondary pattern,
+	// and rules written for the NFA engine are skipped when RE2 can't compile them.
+	firstGroup       bool
+	secondaryPattern string
+	secondary        *regexp.Regexp
+	engine           string
+}
+
+// matchRuleFile The structured rules file.
+type matchRuleFile struct {
+	Rules []*MatchRule `json:"rules" yaml:"rules"`
+}
+
+// RuleMatch A value found by a rule, located by its byte offset and line in the scope content.
+type RuleMatch struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Severity   string   `json:"severity"`
+	Confidence string   `json:"confidence"`
+	Tags       []string `json:"tags,omitempty"`
+	Scope      string   `json:"scope"`
+	Match      string   `json:"match"`
+	Sha256     string   `json:"sha256"`
+	Offset     int      `json:"offset"`
+	Line       int      `json:"line"`
+	Snippet    string   `json:"snippet"`
+	Redacted   bool     `json:"redacted,omitempty"`
+
+	// Match and Snippet with every secret found in the scope masked, used by RedactMatches.
+	redactedMatch   string
+	redactedSnippet string
+}
+
+// ruleHit The first occurrence of a distinct value, with the part of it checked as a secret.
+type ruleHit struct {
+	Value  string
+	Offset int
+	Secret string
+}
+
+var (
+	matchRulesCache   = make(map[string][]*MatchRule)
+	matchRulesCacheMu sync.Mutex
+)
+
+// LoadMatchRules Read and compile a rules file, the rules are cached by file name.
+// YAML and JSON files hold a "rules" list, a flat JSON "name": "regex" object is loaded as legacy rules.
+func LoadMatchRules(filename string) ([]*MatchRule, error) {
+	matchRulesCacheMu.Lock()
+	defer matchRulesCacheMu.Unlock()
+
+	if rules, ok := matchRulesCache[filename]; ok {
+		return rules, nil
+	}
+
+	data, err := ioutil.ReadFile(filename)
 	if err != nil {
-		log.Fatal("MatchResponseWithJSONRules> Failed to process the JSON file：", err)
-		return
+		return nil, fmt.Errorf("LoadMatchRules> failed to read the file: %w", err)
 	}
 
-	err = json.Unmarshal([]byte(jsonRules), &rules)
+	var parsed []*MatchRule
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yml", ".yaml":
+		parsed, err = parseYAMLMatchRules(data)
+	default:
+		parsed, err = parseJSONMatchRules(data)
+	}
 	if err != nil {
-		fmt.Println("MatchResponseWithJSONRules> Error parsing JSON rules:", err)
+		return nil, err
+	}
+
+	rules := make([]*MatchRule, 0, len(parsed))
+	for _, rule := range parsed {
+		if err := rule.compile(); err != nil {
+			if rule.engine == haeEngineNFA {
+				log.Printf("LoadMatchRules> %s: skip %v", filename, err)
+				continue
+			}
+			return nil, fmt.Errorf("LoadMatchRules> %s: %w", filename, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	matchRulesCache[filename] = rules
+	return rules, nil
+}
+
+// parseYAMLMatchRules Parse the structured format, or HaE's Rules.yml.
+func parseYAMLMatchRules(data []byte) ([]*MatchRule, error) {
+	var hae haeRuleFile
+	if err := yaml.Unmarshal(data, &hae); err == nil && hae.isHaE() {
+		return haeMatchRules(hae), nil
+	}
+
+	var file matchRuleFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("LoadMatchRules> failed to parse YAML: %w", err)
+	}
+	return file.Rules, nil
+}
+
+// parseJSONMatchRules Parse the structured format, or the legacy flat one.
+func parseJSONMatchRules(data []byte) ([]*MatchRule, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("LoadMatchRules> failed to parse JSON: %w", err)
+	}
+
+	if rulesJSON, ok := raw["rules"]; ok && len(rulesJSON) > 0 && rulesJSON[0] == '[' {
+		var file matchRuleFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("LoadMatchRules> failed to parse JSON: %w", err)
+		}
+		return file.Rules, nil
+	}
+
+	var legacy map[string]string
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, fmt.Errorf("LoadMatchRules> failed to parse legacy JSON: %w", err)
+	}
+	return legacyMatchRules(legacy), nil
+}
+
+// legacyMatchRules Turn "name": "regex" pairs into body rules, matched case sensitively as they always were.
+func legacyMatchRules(legacy map[string]string) []*MatchRule {
+	rules := make([]*MatchRule, 0, len(legacy))
+	for name, pattern := range legacy {
+		rules = append(rules, &MatchRule{
+			Name:          name,
+			Patterns:      []string{pattern},
+			CaseSensitive: true,
+			sensitive:     true,
+		})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules
+}
+
+// compile Fill the defaults, check the fields and compile the patterns.
+func (r *MatchRule) compile() error {
+	if r.Name == "" {
+		r.Name = r.ID
+	}
+	if r.ID == "" {
+		r.ID = strings.Trim(reRuleIdUnsafe.ReplaceAllString(strings.ToLower(r.Name), "-"), "-")
+	}
+	if r.ID == "" {
+		return fmt.Errorf("rule without id nor name")
+	}
+
+	r.Severity = strings.ToLower(r.Severity)
+	if r.Severity == "" {
+		r.Severity = "info"
+	}
+	if !ruleSeverities[r.Severity] {
+		return fmt.Errorf("rule %s: unknown severity %q", r.ID, r.Severity)
+	}
+	r.Confidence = strings.ToLower(r.Confidence)
+	if r.Confidence == "" {
+		r.Confidence = "medium"
+	}
+	if !ruleConfidences[r.Confidence] {
+		return fmt.Errorf("rule %s: unknown confidence %q", r.ID, r.Confidence)
+	}
+
+	r.Scope = strings.ToLower(r.Scope)
+	switch r.Scope {
+	case "":
+		r.Scope = RuleScopeBody
+	case RuleScopeBody, RuleScopeHeaders, RuleScopeUrl, RuleScopeTitle, RuleScopeResponse:
+	case RuleScopeHeader:
+		if r.Header == "" {
+			return fmt.Errorf("rule %s: the header scope needs a header name", r.ID)
+		}
+	default:
+		return fmt.Errorf("rule %s: unknown scope %q", r.ID, r.Scope)
+	}
+
+	if len(r.Patterns) == 0 {
+		return fmt.Errorf("rule %s: no pattern", r.ID)
+	}
+	if r.MinMatches < 1 {
+		r.MinMatches = 1
+	}
+	if r.MaxMatches < 1 {
+		r.MaxMatches = defaultRuleMaxMatches
+	}
+	if r.Validator != "" {
+		validate, ok := secretValidators[strings.ToLower(r.Validator)]
+		if !ok {
+			return fmt.Errorf("rule %s: unknown validator %q", r.ID, r.Validator)
+		}
+		r.validate = validate
+	}
+
+	var err error
+	if r.patterns, err = r.compilePatterns(r.Patterns); err != nil {
+		return err
+	}
+	if r.negatives, err = r.compilePatterns(r.NegativePatterns); err != nil {
+		return err
+	}
+	if r.secondaryPattern != "" {
+		secondary, err := r.compilePatterns([]string{r.secondaryPattern})
+		if err != nil {
+			return err
+		}
+		r.secondary = secondary[0]
+	}
+	return nil
+}
+
+func (r *MatchRule) compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if !r.CaseSensitive {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.ID, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Match Return the distinct values the rule finds in the content by offset, nil when it doesn't hit.
+// The caller reports at most MaxMatches of them.
+func (r *MatchRule) Match(content string) []ruleHit {
+	if content == "" {
 		return nil
 	}
+	for _, negative := range r.negatives {
+		if negative.MatchString(content) {
+			return nil
+		}
+	}
 
-	matches = make(map[string]string)
+	var hits []ruleHit
+	seen := make(map[string]int)
+	for _, re := range r.patterns {
+		found := r.findHits(re, content, 0)
+		if r.secondary != nil {
+			var refined []ruleHit
+			for _, hit := range found {
+				refined = append(refined, r.findHits(r.secondary, hit.Value, hit.Offset)...)
+			}
+			found = refined
+		}
+
+		for _, hit := range found {
+			if hit.Value == "" {
+				continue
+			}
+			// Several patterns may find the same value, keep its first occurrence
+			if index, ok := seen[hit.Value]; ok {
+				if hit.Offset < hits[index].Offset {
+					hits[index].Offset = hit.Offset
+				}
+				continue
+			}
+			seen[hit.Value] = len(hits)
+			hits = append(hits, hit)
+		}
+	}
+	if len(hits) < r.MinMatches {
+		return nil
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Offset < hits[j].Offset })
+	return hits
+}
+
+// findHits Return the values of the pattern in the content, their offsets shifted by base.
+func (r *MatchRule) findHits(re *regexp.Regexp, content string, base int) []ruleHit {
+	var hits []ruleHit
+	for _, loc := range re.FindAllStringSubmatchIndex(content, -1) {
+		secret := getSecret(re, content, loc)
+		if !r.isSecret(secret) {
+			continue
+		}
+		start, end := loc[0], loc[1]
+		if r.firstGroup && len(loc) >= 4 && loc[2] >= 0 {
+			start, end = loc[2], loc[3]
+		}
+		value := content[start:end]
+		if !strings.Contains(value, secret) {
+			secret = value
+		}
+		hits = append(hits, ruleHit{Value: value, Offset: base + start, Secret: secret})
+	}
+	return hits
+}
+
+// getSecret Return the secret group of the match when the pattern has one, else the whole match.
+func getSecret(re *regexp.Regexp, content string, loc []int) string {
+	if i := re.SubexpIndex(secretGroup); i > 0 && loc[2*i] >= 0 {
+		return content[loc[2*i]:loc[2*i+1]]
+	}
+	return content[loc[0]:loc[1]]
+}
+
+// isSensitive Whether the rule looks for secrets: tagged as such, checked by entropy or a validator,
+// or loaded from a legacy or HaE file.
+func (r *MatchRule) isSensitive() bool {
+	if r.sensitive || r.Entropy > 0 || r.validate != nil {
+		return true
+	}
+	for _, tag := range r.Tags {
+		if strings.EqualFold(tag, secretGroup) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSecret Check the entropy and the validator of the secret.
+func (r *MatchRule) isSecret(secret string) bool {
+	if r.Entropy > 0 && ShannonEntropy(secret) < r.Entropy {
+		return false
+	}
+	return r.validate == nil || r.validate(secret)
+}
+
+// getLineNumber Return the 1-based line of the offset.
+func getLineNumber(content string, offset int) int {
+	return strings.Count(content[:offset], "\n") + 1
+}
+
+// getSnippet Return the match with ruleSnippetContext bytes around it, cut on UTF-8 boundaries, on one line.
+func getSnippet(content string, offset int, length int) string {
+	start := offset - ruleSnippetContext
+	if start < 0 {
+		start = 0
+	}
+	for start > 0 && !utf8.RuneStart(content[start]) {
+		start--
+	}
+	end := offset + length + ruleSnippetContext
+	if end > len(content) {
+		end = len(content)
+	}
+	for end < len(content) && !utf8.RuneStart(content[end]) {
+		end++
+	}
+	return strings.Join(strings.Fields(content[start:end]), " ")
+}
+
+// getSecretRanges Return the byte ranges of every occurrence of the secret in the content.
+func getSecretRanges(content string, secret string) (ranges [][2]int) {
+	if secret == "" {
+		return nil
+	}
+	for start := 0; ; {
+		i := strings.Index(content[start:], secret)
+		if i < 0 {
+			return ranges
+		}
+		ranges = append(ranges, [2]int{start + i, start + i + len(secret)})
+		start += i + len(secret)
+	}
+}
+
+// getRuleScopeContent Return the part of the response searched by the rule.
+func getRuleScopeContent(resp *Response, rule *MatchRule) string {
+	switch rule.Scope {
+	case RuleScopeHeaders:
+		return getHeadersContent(resp.Headers)
+	case RuleScopeResponse:
+		return getHeadersContent(resp.Headers) + "\n\n" + resp.Raw
+	case RuleScopeHeader:
+		return strings.Join(resp.Headers.Values(rule.Header), "\n")
+	case RuleScopeUrl:
+		return resp.FinalUrl
+	case RuleScopeTitle:
+		return ExtractTitle(resp)
+	}
+	return resp.Raw
+}
+
+// getHeadersContent Return the headers as sorted "Key: value" lines.
+func getHeadersContent(headers http.Header) string {
+	var lines []string
+	for key, values := range headers {
+		for _, value := range values {
+			lines = append(lines, key+": "+value)
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// MatchResponseWithRules Run the rules on their scope of the response and return every finding,
+// the most severe first.
+func MatchResponseWithRules(resp *Response, rules []*MatchRule) (matches []RuleMatch) {
+	// Rules of the same scope share its content, and the secrets found in it
+	contents := make(map[string]string)
+	secrets := make(map[string][][2]int)
+	var scopes []string
+	for _, rule := range rules {
+		scope := rule.Scope + ":" + rule.Header
+		content, ok := contents[scope]
+		if !ok {
+			content = getRuleScopeContent(resp, rule)
+			contents[scope] = content
+		}
+		hits := rule.Match(content)
+		// Every occurrence of every secret is masked, the ones past the cap included
+		if rule.isSensitive() {
+			for _, hit := range hits {
+				secrets[scope] = append(secrets[scope], getSecretRanges(content, hit.Secret)...)
+			}
+		}
+		if len(hits) > rule.MaxMatches {
+			hits = hits[:rule.MaxMatches]
+		}
+		for _, hit := range hits {
+			sum := sha256.Sum256([]byte(hit.Value))
+			match := RuleMatch{
+				ID:         rule.ID,
+				Name:       rule.Name,
+				Severity:   rule.Severity,
+				Confidence: rule.Confidence,
+				Tags:       rule.Tags,
+				Scope:      rule.Scope,
+				Match:      hit.Value,
+				Sha256:     hex.EncodeToString(sum[:]),
+				Offset:     hit.Offset,
+				Line:       getLineNumber(content, hit.Offset),
+				Snippet:    getSnippet(content, hit.Offset, len(hit.Value)),
+			}
+			matches = append(matches, match)
+			scopes = append(scopes, scope)
+		}
+	}
+
+	// The redacted value and snippet are cut from the content with every secret of the scope masked, so that
+	// no finding shows the secret of another one, even partly
+	masked := make(map[string]string, len(secrets))
+	for scope, ranges := range secrets {
+		masked[scope] = maskRanges(contents[scope], ranges)
+	}
+	for i := range matches {
+		content, ok := masked[scopes[i]]
+		if !ok {
+			content = contents[scopes[i]]
+		}
+		end := matches[i].Offset + len(matches[i].Match)
+		matches[i].redactedMatch = content[matches[i].Offset:end]
+		matches[i].redactedSnippet = getSnippet(content, matches[i].Offset, len(matches[i].Match))
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return ruleSeverityRank[matches[i].Severity] < ruleSeverityRank[matches[j].Severity]
+	})
+	return matches
+}
 
-	for key, regexPattern := range rules {
-		re := regexp.MustCompile(regexPattern)
-		match := re.FindString(response)
-		if match != "" {
-			matches[key] = match
+// MatchResponseWithJSONRules Run the body rules of the file on the response text, hits are keyed by rule name.
+func MatchResponseWithJSONRules(response string, rulesFiles string) (matches map[string]string) {
+	rules, err := LoadMatchRules(rulesFiles)
+	if err != nil {
+		log.Println("MatchResponseWithJSONRules> ", err)
+		return nil
+	}
+
+	matches = make(map[string]string)
+	for _, rule := range rules {
+		if rule.Scope != RuleScopeBody {
+			continue
+		}
+		if hits := rule.Match(response); len(hits) > 0 {
+			matches[rule.Name] = hits[0].Value
 		}
 	}
 
@@ -1,6 +1,13 @@
 package utilz
 
-import "testing"
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func TestMatchResponseWithJSONRules(t *testing.T) {
 
@@ -33,3 +40,168 @@ func TestMatchResponseWithJSONRules(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadMatchRulesStructured(t *testing.T) {
+	rules, err := LoadMatchRules("../data/rules_MayVul.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := LoadMatchRules("../data/regex_MayVul.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) < len(legacy) {
+		t.Fatalf("the structured file has %d rules, the legacy one %d", len(rules), len(legacy))
+	}
+	for _, rule := range legacy {
+		if rule.Severity != "info" || rule.Scope != RuleScopeBody || !rule.CaseSensitive {
+			t.Errorf("legacy rule %+v", rule)
+		}
+	}
+
+	// Both files find the same values in the body
+	response := "jdbc:mysql://localhost:3306 and s3.amazonaws.com"
+	if got, want := MatchResponseWithJSONRules(response, "../data/rules_MayVul.json"), MatchResponseWithJSONRules(response, "../data/regex_MayVul.json"); len(got) != len(want) || got["JDBC Connection"] != want["JDBC Connection"] {
+		t.Errorf("structured %v, legacy %v", got, want)
+	}
+}
+
+// findingsByName Group the findings by rule name, keeping their order.
+func findingsByName(findings []RuleMatch) map[string][]RuleMatch {
+	byName := make(map[string][]RuleMatch)
+	for _, finding := range findings {
+		byName[finding.Name] = append(byName[finding.Name], finding)
+	}
+	return byName
+}
+
+func TestMatchResponseWithRules(t *testing.T) {
+	dir := t.TempDir()
+	rulesFile := writeTestFile(t, dir, "rules.yaml", `rules:
+  - id: debug-header
+    name: Debug Header
+    severity: medium
+    scope: header
+    header: X-Debug-Token
+    patterns: ["[0-9a-f]{6}"]
+  - id: admin-title
+    severity: low
+    scope: title
+    patterns: ["admin"]
+  - id: two-emails
+    name: Emails
+    patterns: ['[a-z]+@example\.com']
+    min_matches: 2
+  - id: api-key
+    name: API Key
+    severity: high
+    confidence: high
+    tags: [secret]
+    patterns: ['api_key=[A-Za-z0-9]{8}']
+    negative_patterns: ['api_key=EXAMPLE0']
+    case_sensitive: true
+`)
+
+	rules, err := LoadMatchRules(rulesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &Response{
+		Raw:     "<html><title>ADMIN console</title>bob@example.com bob@example.com API_KEY=abcdefgh",
+		Headers: http.Header{"X-Debug-Token": {"a1b2c3"}},
+	}
+	matches := findingsByName(MatchResponseWithRules(resp, rules))
+	if match := matches["Debug Header"]; len(match) != 1 || match[0].Severity != "medium" || match[0].Match != "a1b2c3" {
+		t.Errorf("Debug Header: %+v", match)
+	}
+	if match := matches["admin-title"]; len(match) != 1 || match[0].Match != "ADMIN" {
+		t.Errorf("admin-title: %+v", match)
+	}
+	// A single distinct email, a case sensitive pattern not matching API_KEY
+	for _, name := range []string{"Emails", "API Key"} {
+		if _, ok := matches[name]; ok {
+			t.Errorf("%s should not match", name)
+		}
+	}
+
+	resp.Raw = "alice@example.com bob@example.com api_key=abcdefgh"
+	matches = findingsByName(MatchResponseWithRules(resp, rules))
+	if match := matches["API Key"]; len(match) != 1 || match[0].Severity != "high" || match[0].Tags[0] != "secret" {
+		t.Errorf("API Key: %+v", match)
+	}
+	if match := matches["Emails"]; len(match) != 2 {
+		t.Errorf("Emails should match two distinct values: %+v", match)
+	}
+
+	// A negative pattern suppresses the rule
+	resp.Raw = "api_key=abcdefgh api_key=EXAMPLE0"
+	if _, ok := findingsByName(MatchResponseWithRules(resp, rules))["API Key"]; ok {
+		t.Error("API Key should be suppressed by its negative pattern")
+	}
+}
+
+func TestMatchResponseWithRulesLocations(t *testing.T) {
+	dir := t.TempDir()
+	rulesFile := writeTestFile(t, dir, "rules.yaml", `rules:
+  - id: token
+    severity: low
+    patterns: ['token-[0-9]+']
+    max_matches: 2
+  - id: password
+    severity: high
+    patterns: ['password=\w+']
+`)
+
+	rules, err := LoadMatchRules(rulesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := "line one\ntoken-1 token-1\n  token-2\n" + strings.Repeat("x", 60) + " password=hunter2 " + strings.Repeat("y", 60) + "\ntoken-3"
+	matches := MatchResponseWithRules(&Response{Raw: body}, rules)
+
+	// The most severe first, then the distinct tokens by offset up to the cap
+	if len(matches) != 3 || matches[0].ID != "password" {
+		t.Fatalf("unexpected findings: %+v", matches)
+	}
+	password := matches[0]
+	if password.Offset != strings.Index(body, "password=") || password.Line != 4 {
+		t.Errorf("password location: offset %d line %d", password.Offset, password.Line)
+	}
+	if want := strings.Repeat("x", 39) + " password=hunter2 " + strings.Repeat("y", 39); password.Snippet != want {
+		t.Errorf("password snippet: %q", password.Snippet)
+	}
+	if token := matches[1]; token.Match != "token-1" || token.Offset != 9 || token.Line != 2 || token.Snippet != "line one token-1 token-1 token-2 "+strings.Repeat("x", 21) {
+		t.Errorf("first token: %+v", token)
+	}
+	if token := matches[2]; token.Match != "token-2" || token.Line != 3 {
+		t.Errorf("second token: %+v", token)
+	}
+}
+
+func TestLoadMatchRulesErrors(t *testing.T) {
+	dir := t.TempDir()
+	for i, content := range []string{
+		`{"rules": [{"id": "a", "patterns": ["("]}]}`,
+		`{"rules": [{"id": "a", "severity": "urgent", "patterns": ["x"]}]}`,
+		`{"rules": [{"id": "a", "scope": "header", "patterns": ["x"]}]}`,
+		`{"rules": [{"id": "a", "scope": "cookie", "patterns": ["x"]}]}`,
+		`{"rules": [{"id": "a"}]}`,
+	} {
+		rulesFile := writeTestFile(t, dir, fmt.Sprintf("rules%d.json", i), content)
+		if _, err := LoadMatchRules(rulesFile); err == nil {
+			t.Errorf("%s: expected an error", content)
+		}
+	}
+}
+
+// writeTestFile Write a fixture file in dir and return its path.
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
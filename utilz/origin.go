@@ -0,0 +1,218 @@
+package utilz
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Origin candidate sources.
+const (
+	OriginSourceDNSHistory = "dns_history"
+	OriginSourceSibling    = "sibling"
+	OriginSourceMX         = "mx"
+	OriginSourceSPF        = "spf"
+	OriginSourceSAN        = "san"
+)
+
+// OriginCandidate An IP which may be the origin server behind the CDN, verified by requesting it directly
+// with the target Host header and comparing the answer with the fronted one.
+type OriginCandidate struct {
+	IP       string            `json:"ip"`
+	Sources  []string          `json:"sources"`
+	Verified bool              `json:"verified"`
+	Diff     []string          `json:"diff,omitempty"`
+	Response *VhostFingerprint `json:"response,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// OriginInfo Origin candidates of a CDN fronted target.
+type OriginInfo struct {
+	Url        string             `json:"url"`
+	Host       string             `json:"host"`
+	Fronted    *VhostFingerprint  `json:"fronted"`
+	Candidates []*OriginCandidate `json:"candidates"`
+}
+
+// LoadDNSHistory Read historical A records, one "hostname ip" pair per line, separated by spaces or commas.
+// Extra columns, e.g. dates, and # comment lines are ignored.
+func LoadDNSHistory(filename string) map[string][]string {
+	history := make(map[string][]string)
+	for _, line := range FileContentToList(filename) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(fields) < 2 || net.ParseIP(fields[1]) == nil {
+			continue
+		}
+		host := strings.ToLower(strings.TrimSuffix(fields[0], "."))
+		history[host] = append(history[host], fields[1])
+	}
+	return history
+}
+
+// GetMailIPsByDomain Return the IPs of the MX hosts and the ip4/ip6/a mechanisms of the SPF record of the domain.
+// Mail servers often share the origin network, CIDR mechanisms wider than a single address are skipped.
+func GetMailIPsByDomain(domain string, resolversFile string) (mxIps, spfIps []string) {
+	dnsxClient := dnsxClientByTypes(resolversFile, dns.TypeMX, dns.TypeTXT)
+	if dnsxClient == nil {
+		return
+	}
+	data, err := dnsxClient.QueryMultiple(domain)
+	if err != nil || data == nil {
+		return
+	}
+
+	aClient := DnsxClient(domain, resolversFile)
+	resolve := func(host string) []string {
+		if aClient == nil {
+			return nil
+		}
+		result, err := aClient.QueryOne(strings.TrimSuffix(host, "."))
+		if err != nil || result == nil {
+			return nil
+		}
+		return result.A
+	}
+
+	for _, mx := range data.MX {
+		mxIps = append(mxIps, resolve(mx)...)
+	}
+
+	for _, txt := range data.TXT {
+		if !strings.HasPrefix(strings.ToLower(txt), "v=spf1") {
+			continue
+		}
+		for _, mechanism := range strings.Fields(txt) {
+			mechanism = strings.TrimLeft(strings.ToLower(mechanism), "+")
+			name, value, _ := strings.Cut(mechanism, ":")
+			switch name {
+			case "ip4", "ip6":
+				if ip := getSingleIP(value); ip != "" {
+					spfIps = append(spfIps, ip)
+				}
+			case "a":
+				host := domain
+				if value != "" {
+					host, _, _ = strings.Cut(value, "/")
+				}
+				spfIps = append(spfIps, resolve(host)...)
+			}
+		}
+	}
+	return UniqueStrList(mxIps), UniqueStrList(spfIps)
+}
+
+// getSingleIP Return the address of "ip" or "ip/32" ("ip/128"), empty for wider networks.
+func getSingleIP(value string) string {
+	if ip := net.ParseIP(value); ip != nil {
+		return ip.String()
+	}
+	ip, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return ""
+	}
+	if ones, bits := network.Mask.Size(); ones != bits {
+		return ""
+	}
+	return ip.String()
+}
+
+// MatchSAN Report whether a certificate SAN covers the host, wildcards matching one label.
+func MatchSAN(host string, sans []string) bool {
+	host = strings.ToLower(host)
+	for _, san := range sans {
+		san = strings.ToLower(san)
+		if san == host {
+			return true
+		}
+		if strings.HasPrefix(san, "*.") {
+			if index := strings.Index(host, "."); index > 0 && host[index:] == san[1:] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReplaceUrlHost Return the url pointing to the ip, the port and path are kept.
+func ReplaceUrlHost(targetUrl string, ip string) (string, error) {
+	parsed, err := url.Parse(targetUrl)
+	if err != nil {
+		return "", err
+	}
+	if port := parsed.Port(); port != "" {
+		parsed.Host = net.JoinHostPort(ip, port)
+	} else if strings.Contains(ip, ":") {
+		parsed.Host = "[" + ip + "]"
+	} else {
+		parsed.Host = ip
+	}
+	return parsed.String(), nil
+}
+
+// AddOriginCandidate Record the ip as candidate from the source, candidates are kept sorted by IP.
+func AddOriginCandidate(candidates []*OriginCandidate, ip string, source string) []*OriginCandidate {
+	for _, candidate := range candidates {
+		if candidate.IP == ip {
+			for _, known := range candidate.Sources {
+				if known == source {
+					return candidates
+				}
+			}
+			candidate.Sources = append(candidate.Sources, source)
+			return candidates
+		}
+	}
+	candidates = append(candidates, &OriginCandidate{IP: ip, Sources: []string{source}})
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].IP < candidates[j].IP })
+	return candidates
+}
+
+// VerifyOriginCandidates Request every candidate directly with the target Host header: a candidate answering
+// the same status, length and title as the fronted target is verified. CDN IPs are dropped.
+func (config *RequestClientConfig) VerifyOriginCandidates(targetUrl string, candidates []*OriginCandidate) *OriginInfo {
+	host, err := GetSubDomain(targetUrl)
+	if err != nil {
+		return nil
+	}
+	if parsed, err := url.Parse(targetUrl); err == nil && parsed.Hostname() != "" {
+		host = parsed.Hostname()
+	}
+
+	info := &OriginInfo{Url: targetUrl, Host: host}
+	fronted, err := config.GetVhostFingerprint(targetUrl, host)
+	if err != nil {
+		return nil
+	}
+	info.Fronted = fronted
+
+	for _, candidate := range candidates {
+		if _, cdn := GetCDNInfoByIps([]string{candidate.IP}); cdn {
+			continue
+		}
+		info.Candidates = append(info.Candidates, candidate)
+
+		candidateUrl, err := ReplaceUrlHost(targetUrl, candidate.IP)
+		if err != nil {
+			candidate.Error = err.Error()
+			continue
+		}
+		response, err := config.GetVhostFingerprint(candidateUrl, host)
+		if err != nil {
+			candidate.Error = err.Error()
+			continue
+		}
+		candidate.Response = response
+		candidate.Diff = CompareVhost(response, fronted)
+		candidate.Verified = len(candidate.Diff) == 0
+	}
+	return info
+}
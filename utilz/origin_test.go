@@ -0,0 +1,79 @@
+package utilz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadDNSHistory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history.txt")
+	if err := os.WriteFile(file, []byte("# host ip date\nWWW.example.com. 203.0.113.10 2019-01-01\nwww.example.com,203.0.113.11\nbroken line\napi.example.com not-an-ip\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	history := LoadDNSHistory(file)
+	if got := history["www.example.com"]; len(got) != 2 || got[0] != "203.0.113.10" || got[1] != "203.0.113.11" {
+		t.Fatalf("got %v", got)
+	}
+	if len(history) != 1 {
+		t.Fatalf("got %d hosts, want 1", len(history))
+	}
+}
+
+func TestOriginHelpers(t *testing.T) {
+	for value, want := range map[string]string{"203.0.113.1": "203.0.113.1", "203.0.113.1/32": "203.0.113.1", "203.0.113.0/24": "", "2001:db8::1/128": "2001:db8::1", "bad": ""} {
+		if got := getSingleIP(value); got != want {
+			t.Errorf("getSingleIP(%q) = %q, want %q", value, got, want)
+		}
+	}
+
+	sans := []string{"example.com", "*.example.com"}
+	for host, want := range map[string]bool{"example.com": true, "www.example.com": true, "a.b.example.com": false, "example.org": false} {
+		if got := MatchSAN(host, sans); got != want {
+			t.Errorf("MatchSAN(%q) = %v, want %v", host, got, want)
+		}
+	}
+
+	for ip, want := range map[string]string{"203.0.113.1": "https://203.0.113.1:8443/login?a=1", "2001:db8::1": "https://[2001:db8::1]:8443/login?a=1"} {
+		if got, _ := ReplaceUrlHost("https://www.example.com:8443/login?a=1", ip); got != want {
+			t.Errorf("ReplaceUrlHost(%q) = %q, want %q", ip, got, want)
+		}
+	}
+
+	candidates := AddOriginCandidate(nil, "203.0.113.2", OriginSourceSibling)
+	candidates = AddOriginCandidate(candidates, "203.0.113.1", OriginSourceMX)
+	candidates = AddOriginCandidate(candidates, "203.0.113.2", OriginSourceSAN)
+	candidates = AddOriginCandidate(candidates, "203.0.113.2", OriginSourceSAN)
+	if len(candidates) != 2 || candidates[0].IP != "203.0.113.1" || strings.Join(candidates[1].Sources, ",") != "sibling,san" {
+		t.Fatalf("got %+v %+v", candidates[0], candidates[1])
+	}
+}
+
+func TestVerifyOriginCandidates(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><title>App</title><body>welcome</body></html>"))
+	}))
+	defer origin.Close()
+
+	// The fronted target is the origin itself, 127.0.0.2 doesn't listen.
+	targetUrl := origin.URL
+	config := &RequestClientConfig{Headers: map[string]string{}, Timeout: 5}
+
+	info := config.VerifyOriginCandidates(targetUrl, []*OriginCandidate{
+		{IP: "127.0.0.1", Sources: []string{OriginSourceDNSHistory}},
+		{IP: "127.0.0.2", Sources: []string{OriginSourceSibling}},
+	})
+	if info == nil || info.Fronted == nil || info.Fronted.Title != "App" {
+		t.Fatalf("got %+v", info)
+	}
+	if !info.Candidates[0].Verified {
+		t.Errorf("127.0.0.1 not verified: %+v", info.Candidates[0])
+	}
+	if info.Candidates[1].Verified || info.Candidates[1].Error == "" {
+		t.Errorf("127.0.0.2 should fail: %+v", info.Candidates[1])
+	}
+}
@@ -0,0 +1,88 @@
+package utilz
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// PageInfo Metadata of an HTML page, useful to spot login portals and CMS installs.
+type PageInfo struct {
+	Description    string            `json:"description,omitempty"`
+	Keywords       string            `json:"keywords,omitempty"`
+	Generator      string            `json:"generator,omitempty"`
+	Canonical      string            `json:"canonical,omitempty"`
+	OpenGraph      map[string]string `json:"opengraph,omitempty"`
+	H1             string            `json:"h1,omitempty"`
+	Forms          int               `json:"forms"`
+	PasswordInputs int               `json:"password_inputs"`
+}
+
+// ExtractPageInfo Collect the meta tags, canonical url, first <h1> and form counts in one walk of the DOM.
+func ExtractPageInfo(r *Response) *PageInfo {
+	htmlDoc, err := r.Document()
+	if err != nil {
+		return nil
+	}
+
+	page := &PageInfo{}
+	var crawler func(*html.Node)
+	crawler = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			switch node.Data {
+			case "meta":
+				name := strings.ToLower(getNodeAttr(node, "name"))
+				property := strings.ToLower(getNodeAttr(node, "property"))
+				content := getNodeAttr(node, "content")
+				switch {
+				case name == "description":
+					page.Description = content
+				case name == "keywords":
+					page.Keywords = content
+				case name == "generator":
+					page.Generator = content
+				case strings.HasPrefix(property, "og:"):
+					if page.OpenGraph == nil {
+						page.OpenGraph = make(map[string]string)
+					}
+					page.OpenGraph[property] = content
+				}
+			case "link":
+				for _, rel := range strings.Fields(strings.ToLower(getNodeAttr(node, "rel"))) {
+					if rel == "canonical" {
+						page.Canonical = getNodeAttr(node, "href")
+					}
+				}
+			case "h1":
+				if page.H1 == "" {
+					page.H1 = strings.Join(strings.Fields(getNodeText(node)), " ")
+				}
+			case "form":
+				page.Forms++
+			case "input":
+				if strings.EqualFold(getNodeAttr(node, "type"), "password") {
+					page.PasswordInputs++
+				}
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			crawler(child)
+		}
+	}
+	crawler(htmlDoc)
+
+	return page
+}
+
+// getNodeText Return the text content of the node and its children.
+func getNodeText(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+	var builder strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		builder.WriteString(getNodeText(child))
+		builder.WriteByte(' ')
+	}
+	return builder.String()
+}
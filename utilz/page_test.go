@@ -0,0 +1,56 @@
+package utilz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExtractPageInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<!DOCTYPE html>
+<html><head>
+<title>Sign in</title>
+<META NAME="Description" content=" Customer portal ">
+<meta name="keywords" content="portal, login">
+<meta name="generator" content="WordPress 6.4.2">
+<meta property="og:title" content="Portal">
+<meta property="OG:Type" content="website">
+<link rel="alternate canonical" href="https://portal.example.com/">
+</head><body>
+<h1>  Welcome
+  <b>back</b> </h1>
+<h1>Second</h1>
+<form action="/login"><input name="user"><input type="PASSWORD" name="pass"></form>
+<form action="/search"><input name="q"></form>
+</body></html>`))
+	}))
+	defer server.Close()
+
+	config := &RequestClientConfig{Headers: map[string]string{}, Timeout: 5}
+	resp, err := config.GetResponseByUrl(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := ExtractPageInfo(resp)
+	if page == nil {
+		t.Fatal("no page info")
+	}
+	if page.Description != "Customer portal" || page.Keywords != "portal, login" || page.Generator != "WordPress 6.4.2" {
+		t.Errorf("unexpected meta tags: %+v", page)
+	}
+	if page.Canonical != "https://portal.example.com/" || page.H1 != "Welcome back" {
+		t.Errorf("unexpected canonical or h1: %+v", page)
+	}
+	if len(page.OpenGraph) != 2 || page.OpenGraph["og:title"] != "Portal" || page.OpenGraph["og:type"] != "website" {
+		t.Errorf("got opengraph %v", page.OpenGraph)
+	}
+	if page.Forms != 2 || page.PasswordInputs != 1 {
+		t.Errorf("got %d forms and %d password inputs", page.Forms, page.PasswordInputs)
+	}
+
+	if empty := ExtractPageInfo(&Response{Raw: `{"status": "ok"}`}); empty == nil || empty.Forms != 0 || empty.OpenGraph != nil {
+		t.Errorf("got %+v for a JSON body", empty)
+	}
+}
@@ -0,0 +1,45 @@
+package utilz
+
+import (
+	"os"
+	"strings"
+)
+
+// ParseList Return the items listed in a file, one per line, or given inline as a comma separated list.
+// Blank items and # comment lines are dropped.
+func ParseList(list string) []string {
+	var items []string
+	if info, err := os.Stat(list); err == nil && !info.IsDir() {
+		items = FileContentToList(list)
+	} else {
+		items = strings.Split(list, ",")
+	}
+
+	var result []string
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item != "" && !strings.HasPrefix(item, "#") {
+			result = append(result, item)
+		}
+	}
+	return UniqueStrList(result)
+}
+
+// ParsePaths Return the paths listed in a file, one per line, or given inline as a comma separated list.
+func ParsePaths(pathList string) []string {
+	paths := ParseList(pathList)
+	for i, path := range paths {
+		if !strings.HasPrefix(path, "/") {
+			paths[i] = "/" + path
+		}
+	}
+	return UniqueStrList(paths)
+}
+
+// JoinUrlPath Append the path to the base url, the base path is kept and its query is dropped.
+func JoinUrlPath(baseUrl string, path string) string {
+	if index := strings.IndexAny(baseUrl, "?#"); index >= 0 {
+		baseUrl = baseUrl[:index]
+	}
+	return strings.TrimRight(baseUrl, "/") + "/" + strings.TrimLeft(path, "/")
+}
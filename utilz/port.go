@@ -0,0 +1,228 @@
+package utilz
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// portPresets Named port lists that can be used in place of explicit ports.
+var portPresets = map[string][]int{
+	"http-common": {80, 443, 8000, 8008, 8080, 8081, 8443, 8888, 9000, 9090, 9443},
+	"http-admin":  {2082, 2083, 2086, 2087, 7001, 7002, 8080, 8081, 8088, 8443, 8834, 8880, 9000, 9043, 9060, 9200, 10000},
+	"http-top": {80, 81, 88, 443, 591, 593, 800, 808, 3000, 3001, 4443, 5000, 5001, 5601, 7001, 7002, 7080, 7443,
+		8000, 8001, 8008, 8009, 8080, 8081, 8082, 8083, 8088, 8089, 8090, 8181, 8443, 8834, 8880, 8888, 9000,
+		9001, 9043, 9080, 9090, 9200, 9443, 10000, 10443},
+}
+
+// ParsePorts Parse a port list such as "80,443,8000-8010,http-common" into a sorted list of unique ports.
+func ParsePorts(portList string) ([]int, error) {
+	uniquePorts := make(map[int]bool)
+
+	for _, item := range strings.Split(portList, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if preset, ok := portPresets[strings.ToLower(item)]; ok {
+			for _, port := range preset {
+				uniquePorts[port] = true
+			}
+			continue
+		}
+
+		if strings.Contains(item, "-") {
+			bounds := strings.SplitN(item, "-", 2)
+			start, err := parsePort(bounds[0])
+			if err != nil {
+				return nil, err
+			}
+			end, err := parsePort(bounds[1])
+			if err != nil {
+				return nil, err
+			}
+			if start > end {
+				return nil, fmt.Errorf("ParsePorts> invalid port range: %s", item)
+			}
+			for port := start; port <= end; port++ {
+				uniquePorts[port] = true
+			}
+			continue
+		}
+
+		port, err := parsePort(item)
+		if err != nil {
+			return nil, err
+		}
+		uniquePorts[port] = true
+	}
+
+	if len(uniquePorts) == 0 {
+		return nil, errors.New("ParsePorts> empty port list")
+	}
+
+	ports := make([]int, 0, len(uniquePorts))
+	for port := range uniquePorts {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+
+	return ports, nil
+}
+
+func parsePort(value string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("ParsePorts> invalid port: %s", value)
+	}
+	return port, nil
+}
+
+// ExpandUrlByPorts Return one scheme-less "host:port/path" target per port, the scheme is detected on request.
+func ExpandUrlByPorts(targetUrl string, ports []int) (targets []string) {
+	host, err := GetSubDomain(targetUrl)
+	if err != nil || host == "" {
+		return
+	}
+
+	// Keep the path and query of the input, if any.
+	var path string
+	if u, err := url.Parse(targetUrl); err == nil && strings.Contains(targetUrl, "://") {
+		path = u.RequestURI()
+		if path == "/" {
+			path = ""
+		}
+	}
+
+	for _, port := range ports {
+		targets = append(targets, net.JoinHostPort(host, strconv.Itoa(port))+path)
+	}
+	return
+}
+
+// GetSchemePortByUrl Return the scheme and port of the url, the port falls back to the scheme default.
+func GetSchemePortByUrl(targetUrl string) (scheme string, port int) {
+	u, err := url.Parse(targetUrl)
+	if err != nil {
+		return
+	}
+	scheme = u.Scheme
+	port, _ = strconv.Atoi(u.Port())
+	if port == 0 {
+		switch scheme {
+		case "https":
+			port = 443
+		case "http":
+			port = 80
+		}
+	}
+	return
+}
+
+// DetectScheme Return "https" if TLS is spoken on host:port, otherwise "http".
+func DetectScheme(host string, port int, timeout time.Duration) (string, error) {
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	dialer := &net.Dialer{Timeout: timeout}
+
+	conn, err := dialer.Dial("tcp", address)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	tlsConn := tls.Client(conn, &tls.Config{
+		InsecureSkipVerify: true,
+		ServerName:         host,
+	})
+	tlsConn.SetDeadline(time.Now().Add(timeout)) //nolint
+	if err := tlsConn.Handshake(); err != nil {
+		// A TLS alert, e.g. a required client certificate, still means the port speaks TLS.
+		if strings.Contains(err.Error(), "remote error: tls:") {
+			return "https", nil
+		}
+		return "http", nil
+	}
+	return "https", nil
+}
+
+// detectScheme Detect the scheme with a raw dial, or with requests through the proxy when one is configured,
+// so that the probe leaves from the same egress as the scan.
+func (config *RequestClientConfig) detectScheme(host string, port int, timeout time.Duration) (string, error) {
+	if config.ProxyURL == "" && config.ProxyPool == nil {
+		// The raw dial doesn't go through GetResponseByUrl, it waits for the buckets here
+		if err := config.RateLimiter.Wait(context.Background(), host); err != nil {
+			return "", err
+		}
+		return DetectScheme(host, port, timeout)
+	}
+
+	probeConfig := *config
+	probeConfig.Method = http.MethodHead
+	probeConfig.FollowRedirects = false
+	probeConfig.Retries = 0
+	// Only the handshake matters, not the certificate validity.
+	probeConfig.TLS.InsecureSkipVerify = true
+
+	hostPort := net.JoinHostPort(host, strconv.Itoa(port))
+	if _, err := probeConfig.GetResponseByUrl("https://" + hostPort); err == nil {
+		return "https", nil
+	}
+	if _, err := probeConfig.GetResponseByUrl("http://" + hostPort); err != nil {
+		return "", err
+	}
+	return "http", nil
+}
+
+// DetectUrlScheme Prefix a scheme-less "host[:port][/path]" target with the scheme spoken on its port.
+// Without a port, 443 is tried first and 80 is used as fallback.
+func (config *RequestClientConfig) DetectUrlScheme(target string) (string, error) {
+	if strings.Contains(target, "://") {
+		return target, nil
+	}
+
+	timeout := config.Timeout * time.Second
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	hostPort, path := target, ""
+	if index := strings.Index(target, "/"); index >= 0 {
+		hostPort, path = target[:index], target[index:]
+	}
+
+	host, portStr, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		// No port given, probe the default TLS port.
+		if scheme, err := config.detectScheme(hostPort, 443, timeout); err == nil && scheme == "https" {
+			return "https://" + hostPort + path, nil
+		}
+		return "http://" + hostPort + path, nil
+	}
+
+	port, err := parsePort(portStr)
+	if err != nil {
+		return "", err
+	}
+	scheme, err := config.detectScheme(host, port, timeout)
+	if err != nil {
+		return "", err
+	}
+
+	// Drop the port when it is the scheme default so the url stays canonical.
+	if (scheme == "https" && port == 443) || (scheme == "http" && port == 80) {
+		hostPort = host
+		if strings.Contains(host, ":") {
+			hostPort = "[" + host + "]"
+		}
+	}
+	return scheme + "://" + hostPort + path, nil
+}
@@ -0,0 +1,41 @@
+package utilz
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	ports, err := ParsePorts("8443, 80,8000-8002,80")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectedPorts := []int{80, 8000, 8001, 8002, 8443}
+	if !reflect.DeepEqual(ports, expectedPorts) {
+		t.Errorf("Expected ports %v, but got %v", expectedPorts, ports)
+	}
+
+	ports, err = ParsePorts("http-common")
+	if err != nil || len(ports) != len(portPresets["http-common"]) {
+		t.Errorf("Expected preset http-common to expand, got %v (%v)", ports, err)
+	}
+
+	for _, invalid := range []string{"", "0", "65536", "90-80", "abc"} {
+		if _, err := ParsePorts(invalid); err == nil {
+			t.Errorf("Expected error for port list '%s'", invalid)
+		}
+	}
+}
+
+func TestExpandUrlByPorts(t *testing.T) {
+	targets := ExpandUrlByPorts("https://www.example.com/admin?x=1", []int{80, 8443})
+	expectedTargets := []string{"www.example.com:80/admin?x=1", "www.example.com:8443/admin?x=1"}
+	if !reflect.DeepEqual(targets, expectedTargets) {
+		t.Errorf("Expected targets %v, but got %v", expectedTargets, targets)
+	}
+
+	scheme, port := GetSchemePortByUrl("https://www.example.com/admin")
+	if scheme != "https" || port != 443 {
+		t.Errorf("Expected https/443, but got %s/%d", scheme, port)
+	}
+}
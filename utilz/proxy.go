@@ -0,0 +1,267 @@
+package utilz
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+const (
+	ProxyRotationRoundRobin = "round-robin"
+	ProxyRotationRandom     = "random"
+	// proxyMaxFailures Consecutive proxy connection failures after which a proxy is evicted.
+	proxyMaxFailures = 3
+)
+
+// errNoProxy Every proxy of the pool was evicted, the requests fail rather than bypass the proxies.
+var errNoProxy = errors.New("no alive proxy left")
+
+type proxyEntry struct {
+	url      string
+	failures int
+	dead     bool
+}
+
+// ProxyPool Proxies requests are spread over, dead ones are evicted.
+type ProxyPool struct {
+	mu       sync.Mutex
+	rotation string
+	proxies  []*proxyEntry
+	next     int
+}
+
+// NewProxyPool Create a pool from proxy urls, "host:port" entries are http proxies.
+func NewProxyPool(proxies []string, rotation string) (*ProxyPool, error) {
+	switch rotation {
+	case "":
+		rotation = ProxyRotationRoundRobin
+	case ProxyRotationRoundRobin, ProxyRotationRandom:
+	default:
+		return nil, fmt.Errorf("unknown proxy rotation %q", rotation)
+	}
+
+	pool := &ProxyPool{rotation: rotation}
+	seen := make(map[string]bool)
+	for _, item := range proxies {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "://") {
+			item = "http://" + item
+		}
+		proxyURL, err := parseProxyURL(item)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[proxyURL.String()] {
+			seen[proxyURL.String()] = true
+			pool.proxies = append(pool.proxies, &proxyEntry{url: proxyURL.String()})
+		}
+	}
+	if len(pool.proxies) == 0 {
+		return nil, errors.New("no proxy given")
+	}
+	return pool, nil
+}
+
+// LoadProxyFile Read one proxy url per line, blank lines and # comments are skipped.
+func LoadProxyFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var proxies []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		proxies = append(proxies, line)
+	}
+	return proxies, scanner.Err()
+}
+
+// Next Return the proxy of the next request.
+func (p *ProxyPool) Next() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var alive []*proxyEntry
+	for _, entry := range p.proxies {
+		if !entry.dead {
+			alive = append(alive, entry)
+		}
+	}
+	if len(alive) == 0 {
+		return "", errNoProxy
+	}
+
+	if p.rotation == ProxyRotationRandom {
+		return alive[rand.Intn(len(alive))].url, nil
+	}
+	proxyURL := alive[p.next%len(alive)].url
+	p.next++
+	return proxyURL, nil
+}
+
+// Alive Return the proxies not evicted.
+func (p *ProxyPool) Alive() (proxies []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, entry := range p.proxies {
+		if !entry.dead {
+			proxies = append(proxies, entry.url)
+		}
+	}
+	return
+}
+
+// Observe Count the proxy connection failures of a request, the proxy is evicted after proxyMaxFailures in a row.
+func (p *ProxyPool) Observe(proxyURL string, err error) {
+	if p == nil || proxyURL == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, entry := range p.proxies {
+		if entry.url != proxyURL {
+			continue
+		}
+		if !isProxyError(err) {
+			entry.failures = 0
+			return
+		}
+		entry.failures++
+		if entry.failures >= proxyMaxFailures && !entry.dead {
+			entry.dead = true
+			log.Printf("ProxyPool: evict %s after %d failures: %v", redactProxy(entry.url), entry.failures, err)
+		}
+		return
+	}
+}
+
+// CheckHealth Evict the proxies which don't accept a TCP connection within timeout.
+func (p *ProxyPool) CheckHealth(timeout time.Duration) {
+	var wg sync.WaitGroup
+	for _, entry := range p.proxies {
+		wg.Add(1)
+		go func(entry *proxyEntry) {
+			defer wg.Done()
+			proxyURL, _ := url.Parse(entry.url)
+			conn, err := net.DialTimeout("tcp", proxyHostPort(proxyURL), timeout)
+			if err != nil {
+				p.mu.Lock()
+				entry.dead = true
+				p.mu.Unlock()
+				log.Printf("ProxyPool: evict %s: %v", redactProxy(entry.url), err)
+				return
+			}
+			conn.Close()
+		}(entry)
+	}
+	wg.Wait()
+	log.Printf("ProxyPool: %d/%d proxies alive", len(p.Alive()), len(p.proxies))
+}
+
+// parseProxyURL Parse and validate a proxy url: http, https, socks5 (local DNS) or socks5h (remote DNS).
+func parseProxyURL(proxyURL string) (*url.URL, error) {
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", redactProxy(proxyURL), err)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy %q: unsupported scheme %q", redactProxy(proxyURL), parsed.Scheme)
+	}
+	if parsed.Hostname() == "" {
+		return nil, fmt.Errorf("invalid proxy %q: missing host", redactProxy(proxyURL))
+	}
+	return parsed, nil
+}
+
+func proxyHostPort(proxyURL *url.URL) string {
+	if proxyURL.Port() != "" {
+		return proxyURL.Host
+	}
+	port := "1080"
+	switch proxyURL.Scheme {
+	case "http":
+		port = "80"
+	case "https":
+		port = "443"
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+// newSocks5LocalDialer Dial through a socks5 proxy, resolving the target host locally as socks5:// implies.
+// net/http resolves socks5 targets on the proxy side, which is the socks5h behaviour.
+func newSocks5LocalDialer(proxyURL *url.URL) (func(ctx context.Context, network, address string) (net.Conn, error), error) {
+	var auth *proxy.Auth
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+	}
+	dialer, err := proxy.SOCKS5("tcp", proxyHostPort(proxyURL), auth, proxy.Direct)
+	if err != nil {
+		return nil, err
+	}
+	contextDialer := dialer.(proxy.ContextDialer)
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) == nil {
+			ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+			if len(ips) == 0 {
+				return nil, fmt.Errorf("no address for %s", host)
+			}
+			address = net.JoinHostPort(ips[0].IP.String(), port)
+		}
+		return contextDialer.DialContext(ctx, network, address)
+	}, nil
+}
+
+// isProxyError Report whether the request failed to reach or negotiate with the proxy itself.
+// A socks reply error is the proxy relaying the failure of the target, the proxy is fine.
+func isProxyError(err error) bool {
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+	switch {
+	case opErr.Op == "proxyconnect":
+		return true
+	case strings.Contains(opErr.Op, "socks"):
+		return !strings.HasPrefix(opErr.Err.Error(), "unknown error ")
+	}
+	return false
+}
+
+// redactProxy Hide the proxy credentials in logs.
+func redactProxy(proxyURL string) string {
+	if parsed, err := url.Parse(proxyURL); err == nil {
+		return parsed.Redacted()
+	}
+	return proxyURL
+}
@@ -0,0 +1,128 @@
+package utilz
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// adaptiveMinRate The floor of an adaptive host rate, one request every 5 seconds.
+	adaptiveMinRate rate.Limit = 0.2
+	// adaptiveRecovery The factor the host rate grows by after each successful request.
+	adaptiveRecovery = 1.1
+	// adaptiveDefaultRate The rate adaptive hosts start from and recover to when requests aren't limited at all.
+	adaptiveDefaultRate rate.Limit = 10
+)
+
+// RateLimiter Token buckets applied to every request: one global and one per host.
+// In adaptive mode a host rate is halved on 429 responses and connection resets,
+// then recovers progressively up to the configured rate.
+type RateLimiter struct {
+	global   *rate.Limiter
+	hostRate rate.Limit
+	adaptive bool
+
+	mu    sync.Mutex
+	hosts map[string]*rate.Limiter
+}
+
+// NewRateLimiter Create a limiter of globalRate requests per second overall and hostRate per host, 0 means no limit.
+// Adaptive hosts without a configured rate start from the global one, or adaptiveDefaultRate without any.
+func NewRateLimiter(globalRate, hostRate float64, adaptive bool) *RateLimiter {
+	limiter := &RateLimiter{
+		global:   rate.NewLimiter(toLimit(globalRate), 1),
+		hostRate: toLimit(hostRate),
+		adaptive: adaptive,
+		hosts:    make(map[string]*rate.Limiter),
+	}
+	if limiter.adaptive && limiter.hostRate == rate.Inf {
+		limiter.hostRate = limiter.global.Limit()
+		if limiter.hostRate == rate.Inf {
+			limiter.hostRate = adaptiveDefaultRate
+		}
+	}
+	return limiter
+}
+
+func toLimit(perSecond float64) rate.Limit {
+	if perSecond <= 0 {
+		return rate.Inf
+	}
+	return rate.Limit(perSecond)
+}
+
+// hostLimiter Return the token bucket of the host, nil if hosts are not limited.
+func (l *RateLimiter) hostLimiter(host string) *rate.Limiter {
+	if l.hostRate == rate.Inf {
+		return nil
+	}
+	host = strings.ToLower(host)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limiter, ok := l.hosts[host]
+	if !ok {
+		limiter = rate.NewLimiter(l.hostRate, 1)
+		l.hosts[host] = limiter
+	}
+	return limiter
+}
+
+// Wait Block until both the host and the global bucket allow a request.
+func (l *RateLimiter) Wait(ctx context.Context, host string) error {
+	if l == nil {
+		return nil
+	}
+	if limiter := l.hostLimiter(host); limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return l.global.Wait(ctx)
+}
+
+// Observe Feed the outcome of a request to the adaptive mode.
+func (l *RateLimiter) Observe(host string, resp *http.Response, err error) {
+	if l == nil || !l.adaptive {
+		return
+	}
+	limiter := l.hostLimiter(host)
+	if limiter == nil {
+		return
+	}
+
+	current := limiter.Limit()
+	if isThrottled(resp, err) {
+		slower := current / 2
+		if slower < adaptiveMinRate {
+			slower = adaptiveMinRate
+		}
+		if slower < current {
+			limiter.SetLimit(slower)
+			log.Printf("RateLimiter: %s is throttling, slowing down to %.2f req/s", host, float64(slower))
+		}
+		return
+	}
+	if err == nil && current < l.hostRate {
+		faster := current * adaptiveRecovery
+		if faster > l.hostRate {
+			faster = l.hostRate
+		}
+		limiter.SetLimit(faster)
+	}
+}
+
+// isThrottled Report whether the host pushes back: a 429 response or a reset connection.
+func isThrottled(resp *http.Response, err error) bool {
+	if err != nil {
+		return errors.Is(err, syscall.ECONNRESET)
+	}
+	return resp != nil && resp.StatusCode == http.StatusTooManyRequests
+}
@@ -0,0 +1,99 @@
+package utilz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimiterAdaptive(t *testing.T) {
+	limiter := NewRateLimiter(0, 8, true)
+	throttled := &http.Response{StatusCode: http.StatusTooManyRequests}
+	ok := &http.Response{StatusCode: http.StatusOK}
+
+	limiter.Observe("example.com", throttled, nil)
+	if got := limiter.hostLimiter("example.com").Limit(); got != 4 {
+		t.Fatalf("after 429 got %v req/s, want 4", got)
+	}
+	if got := limiter.hostLimiter("other.com").Limit(); got != 8 {
+		t.Fatalf("other host got %v req/s, want 8", got)
+	}
+
+	for i := 0; i < 20; i++ {
+		limiter.Observe("example.com", throttled, nil)
+	}
+	if got := limiter.hostLimiter("example.com").Limit(); got != adaptiveMinRate {
+		t.Fatalf("got %v req/s, want the floor %v", got, adaptiveMinRate)
+	}
+
+	for i := 0; i < 100; i++ {
+		limiter.Observe("example.com", ok, nil)
+	}
+	if got := limiter.hostLimiter("example.com").Limit(); got != 8 {
+		t.Fatalf("after recovery got %v req/s, want 8", got)
+	}
+}
+
+func TestRateLimiterNoHostLimit(t *testing.T) {
+	limiter := NewRateLimiter(50, 0, false)
+	if limiter.hostLimiter("example.com") != nil {
+		t.Fatal("hosts should not be limited without a host rate")
+	}
+
+	adaptive := NewRateLimiter(50, 0, true)
+	if got := adaptive.hostLimiter("example.com").Limit(); got != rate.Limit(50) {
+		t.Fatalf("adaptive host got %v req/s, want the global 50", got)
+	}
+
+	// Without any rate, adaptive hosts still get a limiter to slow down
+	unlimited := NewRateLimiter(0, 0, true)
+	if got := unlimited.hostLimiter("example.com").Limit(); got != adaptiveDefaultRate {
+		t.Fatalf("adaptive host got %v req/s, want %v", got, adaptiveDefaultRate)
+	}
+	unlimited.Observe("example.com", &http.Response{StatusCode: http.StatusTooManyRequests}, nil)
+	if got := unlimited.hostLimiter("example.com").Limit(); got != adaptiveDefaultRate/2 {
+		t.Fatalf("after 429 got %v req/s, want %v", got, adaptiveDefaultRate/2)
+	}
+}
+
+func TestRateLimiterRedirectsAndSchemeDetection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hop, err := strconv.Atoi(r.URL.Path[1:]); err == nil && hop < 3 {
+			http.Redirect(w, r, "/"+strconv.Itoa(hop+1), http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	// 20 requests per second with a burst of 1: the first request is free, the next ones wait 50ms each
+	config := &RequestClientConfig{Headers: map[string]string{}, Timeout: 5, FollowRedirects: true, FollowSameHost: true}
+	config.RateLimiter = NewRateLimiter(0, 20, false)
+	start := time.Now()
+	resp, err := config.GetResponseByUrl(server.URL + "/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.RedirectChain) != 4 {
+		t.Fatalf("got chain %+v", resp.RedirectChain)
+	}
+	if elapsed := time.Since(start); elapsed < 120*time.Millisecond {
+		t.Errorf("4 requests took %s, the redirects didn't wait for the limiter", elapsed)
+	}
+
+	config.RateLimiter = NewRateLimiter(0, 20, false)
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := config.DetectUrlScheme(strings.TrimPrefix(server.URL, "http://")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("3 scheme detections took %s, the probes didn't wait for the limiter", elapsed)
+	}
+}
@@ -1,28 +1,51 @@
 package utilz
 
 import (
-	"crypto/tls"
+	"context"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
+
+	"golang.org/x/net/publicsuffix"
 )
 
 // RequestClientConfig Including configuration options for the requesting client.
 type RequestClientConfig struct {
 	ProxyURL        string
-	UseHTTPS        bool
+	UseHTTPS        bool // Deprecated: skips the certificate verification, use TLS.InsecureSkipVerify.
+	TLS             TLSOptions
+	Host            string // Host header and SNI sent instead of the url host, for vhost probing.
 	FollowRedirects bool
 	MaxRedirects    int
 	Method          string
+	Body            string
 	RandomUserAgent bool
 	Headers         map[string]string
 	FollowSameHost  bool
+	StopCrossDomain bool
 	Timeout         time.Duration
+	MaxBodySize     int64
+	Retries         int
+	RetryOnStatus   bool
+	RateLimiter     *RateLimiter
+	ProxyPool       *ProxyPool
+}
+
+// RedirectHop One response of the redirect chain.
+type RedirectHop struct {
+	Url      string `json:"url"`
+	Status   int    `json:"status"`
+	Location string `json:"location"`
 }
 
+// redirectChainKey Context key of the redirect chain recorded by the client.
+type redirectChainKey struct{}
+
 // NewRequestClient Create a new request client
-func NewRequestClient(config RequestClientConfig) *http.Client {
+func NewRequestClient(config RequestClientConfig) (*http.Client, error) {
 	if config.Timeout == 0 {
 		config.Timeout = 30 * time.Second
 	} else {
@@ -36,16 +59,13 @@ func NewRequestClient(config RequestClientConfig) *http.Client {
 	if config.Method == "" {
 		config.Method = "GET"
 	}
-	if config.RandomUserAgent || len(config.Headers["User-Agent"]) == 0 {
-		config.Headers["User-Agent"] = getRandomUserAgent()
-	}
 	if config.FollowSameHost && config.MaxRedirects == 0 {
 		config.FollowSameHost = false
 	}
 
-	transport := &http.Transport{
-		Proxy:           getProxy(config.ProxyURL),
-		TLSClientConfig: getTLSConfig(config.UseHTTPS),
+	transport, err := getTransport(config)
+	if err != nil {
+		return nil, err
 	}
 
 	client := &http.Client{
@@ -60,36 +80,147 @@ func NewRequestClient(config RequestClientConfig) *http.Client {
 					return http.ErrUseLastResponse
 				}
 			}
+			if config.StopCrossDomain && len(via) > 0 {
+				if GetRegistrableDomain(req.URL.Hostname()) != GetRegistrableDomain(via[len(via)-1].URL.Hostname()) {
+					return http.ErrUseLastResponse
+				}
+			}
 			if len(via) >= config.MaxRedirects {
 				return http.ErrUseLastResponse
 			}
+			// Every hop is a request of its own, it waits for the buckets as the first one did
+			if err := config.RateLimiter.Wait(req.Context(), req.URL.Hostname()); err != nil {
+				return err
+			}
+			// The redirect is followed, record the response which caused it.
+			recordRedirectHop(req.Context(), req.Response)
 			return nil
 		},
 	}
 
-	return client
+	return client, nil
+}
+
+// transportKey The settings a transport depends on, clients sharing them share the transport.
+type transportKey struct {
+	proxyURL string
+	tls      TLSOptions
+}
+
+// transportPool Transports by transportKey, built once per run so that keep-alive connections
+// and TLS sessions are reused across targets.
+var transportPool sync.Map
+
+// transportMaxIdleConnsPerHost Idle connections kept per host, enough for path lists and per host concurrency.
+const transportMaxIdleConnsPerHost = 16
+
+// getTransport Return the shared transport for the proxy and TLS settings of the config. A Host override is
+// also the SNI, so every vhost candidate would add a transport to the pool: those get their own transport
+// without keep-alives instead, whose connection is closed with the response.
+func getTransport(config RequestClientConfig) (*http.Transport, error) {
+	key := transportKey{proxyURL: config.ProxyURL, tls: config.getTLSOptions()}
+	pooled := config.Host == ""
+	if transport, ok := transportPool.Load(key); ok && pooled {
+		return transport.(*http.Transport), nil
+	}
+
+	tlsConfig, err := LoadTLSConfig(key.tls)
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{
+		TLSClientConfig:     tlsConfig,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        100 * transportMaxIdleConnsPerHost,
+		MaxIdleConnsPerHost: transportMaxIdleConnsPerHost,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+		// Bodies are decoded by readBody, which also supports br and zstd and caps the decoded size.
+		DisableCompression: true,
+	}
+	if err := setTransportProxy(transport, config.ProxyURL); err != nil {
+		return nil, err
+	}
+	if !pooled {
+		transport.DisableKeepAlives = true
+		return transport, nil
+	}
+	actual, _ := transportPool.LoadOrStore(key, transport)
+	return actual.(*http.Transport), nil
+}
+
+// CloseIdleConnections Close the idle connections of every shared transport, to be called when a run ends.
+func CloseIdleConnections() {
+	transportPool.Range(func(_, transport interface{}) bool {
+		transport.(*http.Transport).CloseIdleConnections()
+		return true
+	})
+}
+
+// recordRedirectHop Append the response to the redirect chain carried by the request context, if any.
+func recordRedirectHop(ctx context.Context, resp *http.Response) {
+	chain, ok := ctx.Value(redirectChainKey{}).(*[]RedirectHop)
+	if !ok || resp == nil {
+		return
+	}
+	*chain = append(*chain, RedirectHop{
+		Url:      resp.Request.URL.String(),
+		Status:   resp.StatusCode,
+		Location: resp.Header.Get("Location"),
+	})
+}
+
+// GetRegistrableDomain Return the registrable domain (eTLD+1) of the host, IPs and unknown suffixes are returned as is.
+func GetRegistrableDomain(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
 }
 
-// getProxy
-func getProxy(proxyURL string) func(*http.Request) (*url.URL, error) {
-	if proxyURL != "" {
-		proxy, err := url.Parse(proxyURL)
+// setTransportProxy Route the transport through the proxy, socks5 resolves target hosts locally, socks5h remotely.
+func setTransportProxy(transport *http.Transport, proxyURL string) error {
+	if proxyURL == "" {
+		return nil
+	}
+	proxy, err := parseProxyURL(proxyURL)
+	if err != nil {
+		return err
+	}
+	if proxy.Scheme == "socks5" {
+		dial, err := newSocks5LocalDialer(proxy)
 		if err != nil {
-			panic(err)
+			return err
 		}
-		return http.ProxyURL(proxy)
+		transport.DialContext = dial
+		return nil
 	}
+	transport.Proxy = http.ProxyURL(proxy)
 	return nil
 }
 
-// getTLSConfig Return TLS configuration based on the flag indicating the use of HTTPS.
-func getTLSConfig(useHTTPS bool) *tls.Config {
-	if useHTTPS {
-		return &tls.Config{
-			InsecureSkipVerify: true,
-		}
+// GetProxyURL Return the proxy of the next request: one of the pool if any, else the configured one.
+func (config *RequestClientConfig) GetProxyURL() (string, error) {
+	if config.ProxyPool != nil {
+		return config.ProxyPool.Next()
 	}
-	return nil
+	return config.ProxyURL, nil
+}
+
+// getTLSOptions Return the TLS options, with the deprecated UseHTTPS folded in.
+func (config *RequestClientConfig) getTLSOptions() TLSOptions {
+	options := config.TLS
+	if config.UseHTTPS {
+		options.InsecureSkipVerify = true
+	}
+	if options.ServerName == "" {
+		options.ServerName = config.Host
+	}
+	return options
 }
 
 // getRandomUserAgent Return a randomly generated User-Agent string
@@ -0,0 +1,127 @@
+package utilz
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedirectChain(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusMovedPermanently)
+		case "/cross":
+			// The same server under another registrable domain
+			http.Redirect(w, r, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)+"/c", http.StatusFound)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	for _, test := range []struct {
+		name   string
+		path   string
+		config RequestClientConfig
+		chain  []string
+		final  string
+	}{
+		{"followed", "/a", RequestClientConfig{FollowRedirects: true, FollowSameHost: true},
+			[]string{"/a 302 /b", "/b 301 /c", "/c 200 "}, "/c"},
+		{"max redirects", "/a", RequestClientConfig{FollowRedirects: true, FollowSameHost: true, MaxRedirects: 2},
+			[]string{"/a 302 /b", "/b 301 /c"}, "/b"},
+		{"not followed", "/a", RequestClientConfig{},
+			[]string{"/a 302 /b"}, "/a"},
+		{"cross domain stopped", "/cross", RequestClientConfig{FollowRedirects: true, FollowSameHost: true, StopCrossDomain: true},
+			[]string{"/cross 302 localhost/c"}, "/cross"},
+		{"cross domain followed", "/cross", RequestClientConfig{FollowRedirects: true, FollowSameHost: true},
+			[]string{"/cross 302 localhost/c", "localhost/c 200 "}, "localhost/c"},
+	} {
+		config := test.config
+		config.Headers, config.Timeout = map[string]string{}, 5
+		resp, err := config.GetResponseByUrl(server.URL + test.path)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		// Hops as "url status location", with the server address left out
+		strip := func(url string) string {
+			url = strings.TrimPrefix(url, server.URL)
+			return strings.Replace(url, strings.Replace(server.URL, "127.0.0.1", "localhost", 1), "localhost", 1)
+		}
+		var chain []string
+		for _, hop := range resp.RedirectChain {
+			chain = append(chain, fmt.Sprintf("%s %d %s", strip(hop.Url), hop.Status, strip(hop.Location)))
+		}
+		if strings.Join(chain, "|") != strings.Join(test.chain, "|") || strip(resp.FinalUrl) != test.final {
+			t.Errorf("%s: got chain %q to %s, want %q to %s", test.name, chain, resp.FinalUrl, test.chain, test.final)
+		}
+	}
+}
+
+func TestStopCrossDomain(t *testing.T) {
+	config := RequestClientConfig{Headers: map[string]string{}, FollowRedirects: true, FollowSameHost: true, StopCrossDomain: true}
+	client, err := NewRequestClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		from, to string
+		follow   bool
+	}{
+		{"https://www.example.com/", "https://login.example.com/", true},
+		{"https://example.com/", "https://www.example.com/", true},
+		{"https://www.example.com/", "https://example.org/", false},
+		{"https://a.example.co.uk/", "https://b.example.co.uk/", true},
+		{"https://example.co.uk/", "https://other.co.uk/", false},
+		{"https://user.github.io/", "https://other.github.io/", false},
+		{"http://10.0.0.1/", "http://10.0.0.2/", false},
+	} {
+		via, _ := http.NewRequest(http.MethodGet, test.from, nil)
+		req, _ := http.NewRequest(http.MethodGet, test.to, nil)
+		if follow := client.CheckRedirect(req, []*http.Request{via}) == nil; follow != test.follow {
+			t.Errorf("%s to %s: got follow %v", test.from, test.to, follow)
+		}
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	var agents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.UserAgent())
+	}))
+	defer server.Close()
+
+	for _, test := range []struct {
+		name   string
+		config RequestClientConfig
+		random bool
+	}{
+		{"configured", RequestClientConfig{Headers: map[string]string{"User-Agent": "scanner/1.0"}}, false},
+		{"empty", RequestClientConfig{Headers: map[string]string{"User-Agent": ""}}, true},
+		{"random", RequestClientConfig{Headers: map[string]string{"User-Agent": "scanner/1.0"}, RandomUserAgent: true}, true},
+	} {
+		agents = nil
+		config := test.config
+		config.Timeout = 5
+		want := config.Headers["User-Agent"]
+		if _, err := config.GetResponseByUrl(server.URL); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(agents) != 1 {
+			t.Fatalf("%s: got %d requests", test.name, len(agents))
+		}
+		if test.random && (agents[0] == want || !strings.HasPrefix(agents[0], "Mozilla/5.0")) || !test.random && agents[0] != want {
+			t.Errorf("%s: the first request was sent with %q", test.name, agents[0])
+		}
+		if config.Headers["User-Agent"] != want {
+			t.Errorf("%s: the configured headers were changed to %q", test.name, config.Headers["User-Agent"])
+		}
+	}
+}
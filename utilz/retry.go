@@ -0,0 +1,102 @@
+package utilz
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/projectdiscovery/retryablehttp-go"
+)
+
+const (
+	retryWaitMin = 500 * time.Millisecond
+	retryWaitMax = 10 * time.Second
+	// retryAfterMax Longer Retry-After values are capped, a scan can't wait for hours.
+	retryAfterMax = 30 * time.Second
+)
+
+// retryBackoff Exponential backoff with jitter, so that workers hitting the same host spread their retries.
+var retryBackoff = retryablehttp.ExponentialJitterBackoff()
+
+// retryStatus Status codes retried when RetryOnStatus is set.
+var retryStatus = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+}
+
+// shouldRetry Report whether the request is worth another attempt: timeouts, failed dials and refused or
+// reset connections are, 429/502/503 responses only if retryOnStatus is set.
+func shouldRetry(ctx context.Context, resp *http.Response, err error, retryOnStatus bool) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return isTransientError(err)
+	}
+	return retryOnStatus && resp != nil && retryStatus[resp.StatusCode]
+}
+
+// isTransientError Whether the error may not happen again. TLS and certificate errors, unknown hosts
+// and protocol errors would fail the same way on every attempt.
+func isTransientError(err error) bool {
+	var (
+		recordErr    tls.RecordHeaderError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+		dnsErr       *net.DNSError
+		opErr        *net.OpError
+		netErr       net.Error
+	)
+	switch {
+	case errors.As(err, &recordErr), errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return false
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		return false
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET):
+		return true
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	}
+	return false
+}
+
+// getRetryWait Return how long to wait before the next attempt, honoring Retry-After when the server sent one.
+func getRetryWait(attempts int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			if wait > retryAfterMax {
+				wait = retryAfterMax
+			}
+			return wait
+		}
+	}
+	return retryBackoff(retryWaitMin, retryWaitMax, attempts-1, resp)
+}
+
+// parseRetryAfter Parse a Retry-After value, either delay seconds or an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
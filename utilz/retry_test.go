@@ -0,0 +1,107 @@
+package utilz
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// timeoutError A net.Error timing out, like a dial or read deadline.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestShouldRetry(t *testing.T) {
+	wrap := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://example.com", Err: err}
+	}
+	for _, test := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"timeout", wrap(timeoutError{}), true},
+		{"refused", wrap(&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), true},
+		{"reset", wrap(&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), true},
+		{"dial", wrap(&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("network is unreachable")}), true},
+		{"unknown host", wrap(&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "nope.invalid", IsNotFound: true}}), false},
+		{"unknown authority", wrap(x509.UnknownAuthorityError{}), false},
+		{"hostname", wrap(x509.HostnameError{Host: "example.com", Certificate: &x509.Certificate{}}), false},
+		{"protocol", wrap(errors.New("malformed HTTP response")), false},
+	} {
+		if got := shouldRetry(context.Background(), nil, test.err, false); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if shouldRetry(ctx, nil, timeoutError{}, false) {
+		t.Error("canceled: got a retry")
+	}
+
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable}
+	if shouldRetry(context.Background(), resp, nil, false) || !shouldRetry(context.Background(), resp, nil, true) {
+		t.Error("503 should only be retried with retryOnStatus")
+	}
+	if shouldRetry(context.Background(), &http.Response{StatusCode: http.StatusNotFound}, nil, true) {
+		t.Error("404: got a retry")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	for _, test := range []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+		ok    bool
+	}{
+		{"", 0, 0, false},
+		{"120", 120 * time.Second, 120 * time.Second, true},
+		{"0", 0, 0, true},
+		{"-5", 0, 0, false},
+		{"soon", 0, 0, false},
+		{time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), 8 * time.Second, 10 * time.Second, true},
+		{"Mon, 02 Jan 2006 15:04:05 GMT", 0, 0, true},
+	} {
+		wait, ok := parseRetryAfter(test.value)
+		if ok != test.ok || wait < test.min || wait > test.max {
+			t.Errorf("%q: got %s %v, want %s-%s %v", test.value, wait, ok, test.min, test.max, test.ok)
+		}
+	}
+}
+
+func TestGetRetryWait(t *testing.T) {
+	resp := &http.Response{Header: http.Header{"Retry-After": {"3600"}}}
+	if wait := getRetryWait(1, resp); wait != retryAfterMax {
+		t.Errorf("Retry-After: got %s, want the %s cap", wait, retryAfterMax)
+	}
+	if wait := getRetryWait(1, &http.Response{Header: http.Header{"Retry-After": {"2"}}}); wait != 2*time.Second {
+		t.Errorf("Retry-After: got %s", wait)
+	}
+
+	// Exponential backoff with jitter: [min * 2^n, min * 2^(n+1)) for the attempt n, capped at max
+	for attempts := 1; attempts <= 8; attempts++ {
+		low := retryWaitMin << (attempts - 1)
+		high := retryWaitMin << attempts
+		if low > retryWaitMax {
+			low, high = retryWaitMax, retryWaitMax
+		} else if high > retryWaitMax {
+			high = retryWaitMax
+		}
+		for i := 0; i < 20; i++ {
+			if wait := getRetryWait(attempts, nil); wait < low || wait > high {
+				t.Fatalf("attempt %d: got %s, want %s-%s", attempts, wait, low, high)
+			}
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package utilz
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// secretGroup The capture group holding the secret of a match, the whole match is checked without it.
+	secretGroup          = "secret"
+	base62Alphabet       = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	githubChecksumLength = 6
+)
+
+// secretValidators Format and checksum checks of known secret types, by validator name.
+var secretValidators = map[string]func(string) bool{
+	"aws_access_key": ValidateAWSAccessKey,
+	"github_token":   ValidateGitHubToken,
+	"jwt":            ValidateJWT,
+}
+
+var (
+	reAWSAccessKey      = regexp.MustCompile(`^(A3T[A-Z0-9]|AKIA|AGPA|AIDA|AROA|AIPA|ANPA|ANVA|ASIA)[A-Z2-7]{16}$`)
+	reGitHubToken       = regexp.MustCompile(`^gh[pousr]_[A-Za-z0-9]{36}$`)
+	reGitHubFineGrained = regexp.MustCompile(`^github_pat_[A-Za-z0-9]{22}_[A-Za-z0-9]{59}$`)
+)
+
+// ShannonEntropy Return the entropy of the string in bits per character, random base64 is close to 6,
+// words and repeated characters stay low.
+func ShannonEntropy(value string) float64 {
+	if value == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	total := 0
+	for _, c := range value {
+		counts[c]++
+		total++
+	}
+	var entropy float64
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// ValidateAWSAccessKey Check the prefix and the base32 alphabet of an AWS access key id.
+func ValidateAWSAccessKey(value string) bool {
+	return reAWSAccessKey.MatchString(value)
+}
+
+// ValidateGitHubToken Check a GitHub token: classic tokens end with the base62 CRC32 of their 30 random
+// characters, fine-grained tokens are checked on their format.
+func ValidateGitHubToken(value string) bool {
+	if reGitHubFineGrained.MatchString(value) {
+		return true
+	}
+	if !reGitHubToken.MatchString(value) {
+		return false
+	}
+	random, checksum := value[4:len(value)-githubChecksumLength], value[len(value)-githubChecksumLength:]
+	return encodeBase62(uint64(crc32.ChecksumIEEE([]byte(random))), githubChecksumLength) == checksum
+}
+
+// encodeBase62 Encode the number in base62, left padded with 0 to width.
+func encodeBase62(number uint64, width int) string {
+	var encoded []byte
+	for number > 0 {
+		encoded = append([]byte{base62Alphabet[number%62]}, encoded...)
+		number /= 62
+	}
+	for len(encoded) < width {
+		encoded = append([]byte{'0'}, encoded...)
+	}
+	return string(encoded)
+}
+
+// ValidateJWT Check that the header and the payload of the token are base64url JSON objects, with an alg header.
+func ValidateJWT(value string) bool {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	var header, payload map[string]interface{}
+	if !decodeJWTPart(parts[0], &header) || !decodeJWTPart(parts[1], &payload) {
+		return false
+	}
+	_, ok := header["alg"]
+	return ok
+}
+
+func decodeJWTPart(part string, v interface{}) bool {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(part, "="))
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+// Allowlist Known false positives: findings are dropped by the SHA-256 of their value, a regex of their
+// value, or a glob of the url they were found on.
+type Allowlist struct {
+	hashes  map[string]bool
+	regexps []*regexp.Regexp
+	urls    []*regexp.Regexp
+}
+
+// LoadAllowlist Read an allowlist file, one "sha256:<hex>", "regex:<pattern>" or "url:<glob>" entry per line,
+// blank lines and # comments are skipped.
+func LoadAllowlist(filename string) (*Allowlist, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("LoadAllowlist> %w", err)
+	}
+	defer file.Close()
+
+	allowlist := &Allowlist{hashes: make(map[string]bool)}
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kind, value, _ := strings.Cut(line, ":")
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(kind)) {
+		case "sha256":
+			if _, err := hex.DecodeString(value); err != nil || len(value) != sha256.Size*2 {
+				return nil, fmt.Errorf("LoadAllowlist> %s:%d: bad SHA-256 %q", filename, n, value)
+			}
+			allowlist.hashes[strings.ToLower(value)] = true
+		case "regex":
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("LoadAllowlist> %s:%d: %w", filename, n, err)
+			}
+			allowlist.regexps = append(allowlist.regexps, re)
+		case "url":
+			pattern := strings.ReplaceAll(regexp.QuoteMeta(value), `\*`, ".*")
+			allowlist.urls = append(allowlist.urls, regexp.MustCompile("^"+pattern+"$"))
+		default:
+			return nil, fmt.Errorf("LoadAllowlist> %s:%d: unknown entry %q", filename, n, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("LoadAllowlist> %w", err)
+	}
+	return allowlist, nil
+}
+
+// Allowed Whether the finding on the url is a known false positive.
+func (a *Allowlist) Allowed(targetUrl string, match RuleMatch) bool {
+	if a == nil {
+		return false
+	}
+	sum := sha256.Sum256([]byte(match.Match))
+	if a.hashes[hex.EncodeToString(sum[:])] {
+		return true
+	}
+	for _, re := range a.regexps {
+		if re.MatchString(match.Match) {
+			return true
+		}
+	}
+	for _, re := range a.urls {
+		if re.MatchString(targetUrl) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter Return the findings on the url which aren't allowed.
+func (a *Allowlist) Filter(targetUrl string, matches []RuleMatch) []RuleMatch {
+	if a == nil {
+		return matches
+	}
+	var kept []RuleMatch
+	for _, match := range matches {
+		if !a.Allowed(targetUrl, match) {
+			kept = append(kept, match)
+		}
+	}
+	return kept
+}
+
+// RedactSecret Mask the middle of the secret, keeping a quarter of it at each end and at most 4 characters.
+func RedactSecret(secret string) string {
+	runes := []rune(secret)
+	keep := len(runes) / 4
+	if keep > 4 {
+		keep = 4
+	}
+	return string(runes[:keep]) + strings.Repeat("*", len(runes)-2*keep) + string(runes[len(runes)-keep:])
+}
+
+// maskRanges Return the content with the secrets at the byte ranges masked by RedactSecret, the length is kept
+// so that the offsets of the findings still apply. Overlapping secrets are masked over the previous masks.
+func maskRanges(content string, ranges [][2]int) string {
+	masked := []byte(content)
+	for _, r := range ranges {
+		secret := RedactSecret(string(masked[r[0]:r[1]]))
+		if len(secret) == r[1]-r[0] {
+			copy(masked[r[0]:], secret)
+			continue
+		}
+		// Multibyte characters, mask every byte
+		for i := r[0]; i < r[1]; i++ {
+			masked[i] = '*'
+		}
+	}
+	return string(masked)
+}
+
+// RedactMatches Return a copy of the findings with every secret found in the same scope masked in their value
+// and snippet, the SHA-256 of the full value is kept for deduplication and correlation.
+func RedactMatches(matches []RuleMatch) []RuleMatch {
+	redacted := make([]RuleMatch, len(matches))
+	for i, match := range matches {
+		if match.redactedMatch == "" && match.Match != "" {
+			// Not built by MatchResponseWithRules, mask the whole value and drop the snippet
+			match.redactedMatch = RedactSecret(match.Match)
+		}
+		if match.redactedMatch != match.Match {
+			match.Match = match.redactedMatch
+			match.Redacted = true
+		}
+		match.Snippet = match.redactedSnippet
+		match.redactedMatch, match.redactedSnippet = "", ""
+		redacted[i] = match
+	}
+	return redacted
+}
@@ -0,0 +1,305 @@
+package utilz
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// Technology A technology identified on a response.
+type Technology struct {
+	Name       string   `json:"name"`
+	Version    string   `json:"version,omitempty"`
+	Categories []string `json:"categories"`
+}
+
+// techPattern A Wappalyzer style pattern, "regex\;version:\1\;confidence:50".
+type techPattern struct {
+	regex   *regexp.Regexp
+	version string
+}
+
+// techRule The patterns of a technology, keyed by header, cookie or meta name where relevant.
+type techRule struct {
+	Name       string
+	Categories []string
+	Implies    []string
+	Headers    map[string]*techPattern
+	Cookies    map[string]*techPattern
+	Meta       map[string]*techPattern
+	ScriptSrc  []*techPattern
+	Html       []*techPattern
+}
+
+// techRuleJSON The rule as written in the technologies file, patterns are either a string or a list.
+type techRuleJSON struct {
+	Cats      []string          `json:"cats"`
+	Implies   json.RawMessage   `json:"implies"`
+	Headers   map[string]string `json:"headers"`
+	Cookies   map[string]string `json:"cookies"`
+	Meta      map[string]string `json:"meta"`
+	ScriptSrc json.RawMessage   `json:"scriptSrc"`
+	Html      json.RawMessage   `json:"html"`
+}
+
+var (
+	techRulesCache   = make(map[string][]*techRule)
+	techRulesCacheMu sync.Mutex
+)
+
+// loadTechnologyRules Read and compile a Wappalyzer style technologies file, the rules are cached by file name.
+func loadTechnologyRules(filename string) ([]*techRule, error) {
+	techRulesCacheMu.Lock()
+	defer techRulesCacheMu.Unlock()
+
+	if rules, ok := techRulesCache[filename]; ok {
+		return rules, nil
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("loadTechnologyRules> failed to read the file: %w", err)
+	}
+
+	var rawRules map[string]techRuleJSON
+	if err := json.Unmarshal(data, &rawRules); err != nil {
+		return nil, fmt.Errorf("loadTechnologyRules> failed to parse JSON: %w", err)
+	}
+
+	rules := make([]*techRule, 0, len(rawRules))
+	for name, raw := range rawRules {
+		rule := &techRule{
+			Name:       name,
+			Categories: raw.Cats,
+			Implies:    stringOrList(raw.Implies),
+			Headers:    compileTechPatternMap(name, raw.Headers),
+			Cookies:    compileTechPatternMap(name, raw.Cookies),
+			Meta:       compileTechPatternMap(name, raw.Meta),
+		}
+		for _, pattern := range stringOrList(raw.ScriptSrc) {
+			if compiled := compileTechPattern(name, pattern); compiled != nil {
+				rule.ScriptSrc = append(rule.ScriptSrc, compiled)
+			}
+		}
+		for _, pattern := range stringOrList(raw.Html) {
+			if compiled := compileTechPattern(name, pattern); compiled != nil {
+				rule.Html = append(rule.Html, compiled)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+
+	techRulesCache[filename] = rules
+	return rules, nil
+}
+
+func stringOrList(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return list
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return []string{value}
+	}
+	return nil
+}
+
+func compileTechPatternMap(name string, patterns map[string]string) map[string]*techPattern {
+	compiled := make(map[string]*techPattern, len(patterns))
+	for key, pattern := range patterns {
+		if p := compileTechPattern(name, pattern); p != nil {
+			compiled[strings.ToLower(key)] = p
+		}
+	}
+	return compiled
+}
+
+func compileTechPattern(name string, pattern string) *techPattern {
+	parts := strings.Split(pattern, "\\;")
+	re, err := regexp.Compile("(?i)" + parts[0])
+	if err != nil {
+		log.Printf("loadTechnologyRules> %s: invalid pattern %q: %v", name, parts[0], err)
+		return nil
+	}
+
+	compiled := &techPattern{regex: re}
+	for _, option := range parts[1:] {
+		if strings.HasPrefix(option, "version:") {
+			compiled.version = strings.TrimPrefix(option, "version:")
+		}
+	}
+	return compiled
+}
+
+// match Report whether the pattern matches the value and return the version built from the capture groups.
+func (p *techPattern) match(value string) (bool, string) {
+	groups := p.regex.FindStringSubmatch(value)
+	if groups == nil {
+		return false, ""
+	}
+	if p.version == "" {
+		return true, ""
+	}
+
+	version := p.version
+	for i := len(groups) - 1; i > 0; i-- {
+		version = strings.ReplaceAll(version, "\\"+strconv.Itoa(i), groups[i])
+	}
+	return true, strings.TrimSpace(version)
+}
+
+// getScriptSrcMetaByResponse Return the script sources and the meta name/property to content pairs of the page.
+func getScriptSrcMetaByResponse(r *Response) (scripts []string, meta map[string]string) {
+	meta = make(map[string]string)
+
+	htmlDoc, err := r.Document()
+	if err != nil {
+		return
+	}
+
+	var crawler func(*html.Node)
+	crawler = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			switch node.Data {
+			case "script":
+				if src := getNodeAttr(node, "src"); src != "" {
+					scripts = append(scripts, src)
+				}
+			case "meta":
+				name := getNodeAttr(node, "name")
+				if name == "" {
+					name = getNodeAttr(node, "property")
+				}
+				if name != "" {
+					meta[strings.ToLower(name)] = getNodeAttr(node, "content")
+				}
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			crawler(child)
+		}
+	}
+	crawler(htmlDoc)
+
+	return
+}
+
+func getNodeAttr(node *html.Node, key string) string {
+	for _, attr := range node.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return strings.TrimSpace(attr.Val)
+		}
+	}
+	return ""
+}
+
+// DetectTechnologies Match the response headers, cookies, meta tags, script sources and body against the technologies file.
+func DetectTechnologies(r *Response, rulesFile string) (technologies []Technology) {
+	rules, err := loadTechnologyRules(rulesFile)
+	if err != nil {
+		log.Println("DetectTechnologies> ", err)
+		return
+	}
+
+	scripts, meta := getScriptSrcMetaByResponse(r)
+	cookies := make(map[string]string)
+	for _, cookie := range (&http.Response{Header: r.Headers}).Cookies() {
+		cookies[strings.ToLower(cookie.Name)] = cookie.Value
+	}
+
+	found := make(map[string]*Technology)
+	byName := make(map[string]*techRule, len(rules))
+
+	detect := func(rule *techRule, version string) {
+		technology, ok := found[rule.Name]
+		if !ok {
+			technology = &Technology{Name: rule.Name, Categories: rule.Categories}
+			found[rule.Name] = technology
+		}
+		// Keep the most specific version seen
+		if len(version) > len(technology.Version) {
+			technology.Version = version
+		}
+	}
+
+	for _, rule := range rules {
+		byName[rule.Name] = rule
+
+		for header, pattern := range rule.Headers {
+			for _, value := range r.Headers.Values(header) {
+				if ok, version := pattern.match(value); ok {
+					detect(rule, version)
+				}
+			}
+		}
+		for name, pattern := range rule.Cookies {
+			if value, exists := cookies[name]; exists {
+				if ok, version := pattern.match(value); ok {
+					detect(rule, version)
+				}
+			}
+		}
+		for name, pattern := range rule.Meta {
+			if value, exists := meta[name]; exists {
+				if ok, version := pattern.match(value); ok {
+					detect(rule, version)
+				}
+			}
+		}
+		for _, pattern := range rule.ScriptSrc {
+			for _, src := range scripts {
+				if ok, version := pattern.match(src); ok {
+					detect(rule, version)
+				}
+			}
+		}
+		for _, pattern := range rule.Html {
+			if ok, version := pattern.match(r.Raw); ok {
+				detect(rule, version)
+			}
+		}
+	}
+
+	// Add the technologies implied by the detected ones
+	for pending := keysOfTechnologies(found); len(pending) > 0; {
+		name := pending[0]
+		pending = pending[1:]
+		for _, implied := range byName[name].Implies {
+			implied = strings.Split(implied, "\\;")[0]
+			if rule, ok := byName[implied]; ok {
+				if _, exists := found[implied]; !exists {
+					detect(rule, "")
+					pending = append(pending, implied)
+				}
+			}
+		}
+	}
+
+	for _, name := range keysOfTechnologies(found) {
+		technologies = append(technologies, *found[name])
+	}
+	return
+}
+
+func keysOfTechnologies(found map[string]*Technology) []string {
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,86 @@
+package utilz
+
+import (
+	"net/http"
+	"testing"
+)
+
+const technologiesFile = "../data/technologies.json"
+
+func TestTechnologiesFile(t *testing.T) {
+	rules, err := loadTechnologyRules(technologiesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		byName[rule.Name] = true
+	}
+	for _, rule := range rules {
+		for _, implied := range rule.Implies {
+			if !byName[implied] {
+				t.Errorf("%s implies the unknown %s", rule.Name, implied)
+			}
+		}
+	}
+}
+
+func TestDetectTechnologies(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		resp *Response
+		want map[string]string
+	}{
+		{
+			"server version and implies",
+			&Response{Headers: http.Header{"Server": {"openresty/1.21.4.1"}}},
+			map[string]string{"OpenResty": "1.21.4.1", "Nginx": ""},
+		},
+		{
+			"implies chain",
+			&Response{Headers: http.Header{"Set-Cookie": {"_gitlab_session=abc; path=/"}}},
+			map[string]string{"GitLab": "", "Ruby on Rails": ""},
+		},
+		{
+			"cookie",
+			&Response{Headers: http.Header{"Set-Cookie": {"PHPSESSID=abc; path=/", "laravel_session=def; path=/"}}},
+			map[string]string{"PHP": "", "Laravel": ""},
+		},
+		{
+			"meta generator version",
+			&Response{Headers: http.Header{}, Raw: `<html><head><meta name="generator" content="WordPress 6.4.2"></head></html>`},
+			map[string]string{"WordPress": "6.4.2", "PHP": "", "MySQL": ""},
+		},
+		{
+			"script sources",
+			&Response{Headers: http.Header{}, Raw: `<script src="/static/jquery-3.6.0.min.js"></script><script src="/js/vue.2.7.14.min.js"></script>`},
+			map[string]string{"jQuery": "3.6.0", "Vue.js": "2.7.14"},
+		},
+		{
+			"html version",
+			&Response{Headers: http.Header{}, Raw: `<app-root ng-version="16.2.1"></app-root>`},
+			map[string]string{"Angular": "16.2.1"},
+		},
+		{
+			"nothing",
+			&Response{Headers: http.Header{"Server": {"secret"}}, Raw: "<html>hello</html>"},
+			map[string]string{},
+		},
+	} {
+		technologies := DetectTechnologies(test.resp, technologiesFile)
+		got := make(map[string]string, len(technologies))
+		for _, technology := range technologies {
+			got[technology.Name] = technology.Version
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+			continue
+		}
+		for name, version := range test.want {
+			if gotVersion, ok := got[name]; !ok || gotVersion != version {
+				t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,590 @@
+package utilz
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Template matcher types.
+const (
+	TemplateMatcherStatus = "status"
+	TemplateMatcherWord   = "word"
+	TemplateMatcherRegex  = "regex"
+	TemplateMatcherSize   = "size"
+	TemplateMatcherDSL    = "dsl"
+)
+
+// Template extractor types.
+const (
+	TemplateExtractorRegex = "regex"
+	TemplateExtractorKval  = "kval"
+)
+
+// Template parts of the response searched by matchers and extractors.
+const (
+	TemplatePartBody   = "body"
+	TemplatePartHeader = "header"
+	TemplatePartAll    = "all"
+)
+
+// Conditions combining matchers, or the values of a matcher.
+const (
+	TemplateConditionAnd = "and"
+	TemplateConditionOr  = "or"
+)
+
+var reTemplateVariable = regexp.MustCompile(`{{\s*([A-Za-z_][A-Za-z0-9_]*)\s*}}`)
+
+// Template A nuclei style check: requests sent in order, each with its matchers and extractors.
+// Values extracted by a named extractor are available to the next requests as {{name}}.
+type Template struct {
+	ID       string             `yaml:"id"`
+	Info     TemplateInfo       `yaml:"info"`
+	Requests []*TemplateRequest `yaml:"requests"`
+	HTTP     []*TemplateRequest `yaml:"http"`
+}
+
+// TemplateInfo What the template reports.
+type TemplateInfo struct {
+	Name        string `yaml:"name"`
+	Author      string `yaml:"author"`
+	Severity    string `yaml:"severity"`
+	Description string `yaml:"description"`
+	Tags        string `yaml:"tags"`
+}
+
+// TemplateRequest One step of a template. Every path is requested until one matches.
+type TemplateRequest struct {
+	Method            string               `yaml:"method"`
+	Path              []string             `yaml:"path"`
+	Headers           map[string]string    `yaml:"headers"`
+	Body              string               `yaml:"body"`
+	Redirects         bool                 `yaml:"redirects"`
+	MaxRedirects      int                  `yaml:"max-redirects"`
+	MatchersCondition string               `yaml:"matchers-condition"`
+	Matchers          []*TemplateMatcher   `yaml:"matchers"`
+	Extractors        []*TemplateExtractor `yaml:"extractors"`
+}
+
+// TemplateMatcher A check of the response, its values are combined with Condition.
+type TemplateMatcher struct {
+	Type            string   `yaml:"type"`
+	Part            string   `yaml:"part"`
+	Condition       string   `yaml:"condition"`
+	Negative        bool     `yaml:"negative"`
+	CaseInsensitive bool     `yaml:"case-insensitive"`
+	Status          []int    `yaml:"status"`
+	Size            []int    `yaml:"size"`
+	Words           []string `yaml:"words"`
+	Regex           []string `yaml:"regex"`
+	DSL             []string `yaml:"dsl"`
+
+	regexps []*regexp.Regexp
+	dsl     []dslNode
+}
+
+// TemplateExtractor Values taken from the response, reported unless Internal.
+type TemplateExtractor struct {
+	Name     string   `yaml:"name"`
+	Type     string   `yaml:"type"`
+	Part     string   `yaml:"part"`
+	Regex    []string `yaml:"regex"`
+	Group    int      `yaml:"group"`
+	Kval     []string `yaml:"kval"`
+	Internal bool     `yaml:"internal"`
+
+	regexps []*regexp.Regexp
+}
+
+// TemplateResult A matched template, with the request that matched and the extracted values.
+type TemplateResult struct {
+	ID        string              `json:"id"`
+	Name      string              `json:"name"`
+	Severity  string              `json:"severity"`
+	Tags      []string            `json:"tags,omitempty"`
+	Url       string              `json:"url"`
+	Method    string              `json:"method"`
+	Status    int                 `json:"status_code"`
+	Extracted map[string][]string `json:"extracted,omitempty"`
+}
+
+// LoadTemplates Load the templates of a comma separated list of files and directories, directories are
+// walked for .yaml and .yml files.
+func LoadTemplates(paths string) ([]*Template, error) {
+	var templates []*Template
+	for _, path := range strings.Split(paths, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("LoadTemplates> %w", err)
+		}
+		if !info.IsDir() {
+			template, err := LoadTemplate(path)
+			if err != nil {
+				return nil, err
+			}
+			templates = append(templates, template)
+			continue
+		}
+
+		err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			ext := strings.ToLower(filepath.Ext(file))
+			if info.IsDir() || (ext != ".yaml" && ext != ".yml") {
+				return nil
+			}
+			template, err := LoadTemplate(file)
+			if err != nil {
+				return err
+			}
+			templates = append(templates, template)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("LoadTemplates> %w", err)
+		}
+	}
+	return templates, nil
+}
+
+// LoadTemplate Read and compile a template file.
+func LoadTemplate(filename string) (*Template, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("LoadTemplate> failed to read the file: %w", err)
+	}
+	var template Template
+	if err := yaml.Unmarshal(data, &template); err != nil {
+		return nil, fmt.Errorf("LoadTemplate> %s: failed to parse YAML: %w", filename, err)
+	}
+	if err := template.compile(); err != nil {
+		return nil, fmt.Errorf("LoadTemplate> %s: %w", filename, err)
+	}
+	return &template, nil
+}
+
+// compile Fill the defaults, check the fields and compile the patterns and expressions.
+func (t *Template) compile() error {
+	if t.ID == "" {
+		return fmt.Errorf("template without id")
+	}
+	if t.Info.Name == "" {
+		t.Info.Name = t.ID
+	}
+	t.Info.Severity = strings.ToLower(t.Info.Severity)
+	if t.Info.Severity == "" {
+		t.Info.Severity = "info"
+	}
+	if !ruleSeverities[t.Info.Severity] {
+		return fmt.Errorf("template %s: unknown severity %q", t.ID, t.Info.Severity)
+	}
+
+	// "requests" is the older name of "http"
+	t.Requests = append(t.Requests, t.HTTP...)
+	t.HTTP = nil
+	if len(t.Requests) == 0 {
+		return fmt.Errorf("template %s: no request", t.ID)
+	}
+	for i, request := range t.Requests {
+		if err := request.compile(); err != nil {
+			return fmt.Errorf("template %s request %d: %w", t.ID, i+1, err)
+		}
+	}
+	return nil
+}
+
+func (r *TemplateRequest) compile() error {
+	r.Method = strings.ToUpper(r.Method)
+	if r.Method == "" {
+		r.Method = "GET"
+	}
+	if len(r.Path) == 0 {
+		return fmt.Errorf("no path")
+	}
+	var err error
+	if r.MatchersCondition, err = getTemplateCondition(r.MatchersCondition); err != nil {
+		return err
+	}
+	for _, matcher := range r.Matchers {
+		if err := matcher.compile(); err != nil {
+			return err
+		}
+	}
+	for _, extractor := range r.Extractors {
+		if err := extractor.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *TemplateMatcher) compile() error {
+	var err error
+	if m.Condition, err = getTemplateCondition(m.Condition); err != nil {
+		return err
+	}
+	if m.Part, err = getTemplatePart(m.Part); err != nil {
+		return err
+	}
+
+	var values int
+	switch m.Type {
+	case TemplateMatcherStatus:
+		values = len(m.Status)
+	case TemplateMatcherSize:
+		values = len(m.Size)
+	case TemplateMatcherWord:
+		values = len(m.Words)
+	case TemplateMatcherRegex:
+		values = len(m.Regex)
+		if m.regexps, err = compileTemplateRegexps(m.Regex, m.CaseInsensitive); err != nil {
+			return err
+		}
+	case TemplateMatcherDSL:
+		values = len(m.DSL)
+		for _, expression := range m.DSL {
+			node, err := parseDSL(expression)
+			if err != nil {
+				return err
+			}
+			m.dsl = append(m.dsl, node)
+		}
+	default:
+		return fmt.Errorf("unknown matcher type %q", m.Type)
+	}
+	if values == 0 {
+		return fmt.Errorf("%s matcher without value", m.Type)
+	}
+	return nil
+}
+
+func (e *TemplateExtractor) compile() error {
+	var err error
+	if e.Part, err = getTemplatePart(e.Part); err != nil {
+		return err
+	}
+	switch e.Type {
+	case TemplateExtractorRegex:
+		if len(e.Regex) == 0 {
+			return fmt.Errorf("regex extractor without pattern")
+		}
+		e.regexps, err = compileTemplateRegexps(e.Regex, false)
+		return err
+	case TemplateExtractorKval:
+		if len(e.Kval) == 0 {
+			return fmt.Errorf("kval extractor without key")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown extractor type %q", e.Type)
+}
+
+func getTemplateCondition(condition string) (string, error) {
+	switch strings.ToLower(condition) {
+	case "", TemplateConditionOr:
+		return TemplateConditionOr, nil
+	case TemplateConditionAnd:
+		return TemplateConditionAnd, nil
+	}
+	return "", fmt.Errorf("unknown condition %q", condition)
+}
+
+func getTemplatePart(part string) (string, error) {
+	switch strings.ToLower(part) {
+	case "", TemplatePartBody:
+		return TemplatePartBody, nil
+	case TemplatePartHeader, TemplatePartAll:
+		return strings.ToLower(part), nil
+	}
+	return "", fmt.Errorf("unknown part %q", part)
+}
+
+func compileTemplateRegexps(patterns []string, caseInsensitive bool) ([]*regexp.Regexp, error) {
+	regexps := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if caseInsensitive {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		regexps = append(regexps, re)
+	}
+	return regexps, nil
+}
+
+// getTemplateVariables Return the variables of the base url: BaseURL, RootURL, Hostname (with the port),
+// Host, Port, Path and Scheme.
+func getTemplateVariables(baseUrl string) (map[string]string, error) {
+	u, err := url.Parse(baseUrl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("no host in %q", baseUrl)
+	}
+	scheme, port := GetSchemePortByUrl(baseUrl)
+	return map[string]string{
+		"BaseURL":  strings.TrimRight(baseUrl, "/"),
+		"RootURL":  u.Scheme + "://" + u.Host,
+		"Hostname": u.Host,
+		"Host":     u.Hostname(),
+		"Port":     fmt.Sprint(port),
+		"Path":     u.Path,
+		"Scheme":   scheme,
+	}, nil
+}
+
+// expandTemplateVariables Replace the {{variables}}, false when one of them is unknown.
+func expandTemplateVariables(text string, variables map[string]string) (string, bool) {
+	known := true
+	expanded := reTemplateVariable.ReplaceAllStringFunc(text, func(match string) string {
+		value, ok := variables[reTemplateVariable.FindStringSubmatch(match)[1]]
+		if !ok {
+			known = false
+		}
+		return value
+	})
+	return expanded, known
+}
+
+// getTemplatePartContent Return the part of the response.
+func getTemplatePartContent(resp *Response, part string) string {
+	switch part {
+	case TemplatePartHeader:
+		return getHeadersContent(resp.Headers)
+	case TemplatePartAll:
+		return getHeadersContent(resp.Headers) + "\n\n" + resp.Raw
+	}
+	return resp.Raw
+}
+
+// getTemplateDSLEnv Return the DSL variables of the response: body, header (all_headers), status_code,
+// content_length, every header as its lower case name with _ (content_type) and the template variables.
+func getTemplateDSLEnv(resp *Response, variables map[string]string) map[string]interface{} {
+	env := make(map[string]interface{}, len(variables)+len(resp.Headers)+5)
+	for name, value := range variables {
+		env[name] = value
+	}
+	for name := range resp.Headers {
+		env[strings.ReplaceAll(strings.ToLower(name), "-", "_")] = resp.Headers.Get(name)
+	}
+	headers := getHeadersContent(resp.Headers)
+	env["body"] = resp.Raw
+	env["header"] = headers
+	env["all_headers"] = headers
+	env["status_code"] = float64(resp.Status)
+	env["content_length"] = float64(len(resp.Data))
+	return env
+}
+
+// matchTemplateCondition Combine n checks with the condition.
+func matchTemplateCondition(condition string, n int, check func(i int) bool) bool {
+	for i := 0; i < n; i++ {
+		if check(i) != (condition == TemplateConditionAnd) {
+			return condition != TemplateConditionAnd
+		}
+	}
+	return condition == TemplateConditionAnd
+}
+
+// match Whether the response passes the matcher.
+func (m *TemplateMatcher) match(resp *Response, env map[string]interface{}) bool {
+	content := getTemplatePartContent(resp, m.Part)
+	var matched bool
+	switch m.Type {
+	case TemplateMatcherStatus:
+		matched = matchTemplateCondition(TemplateConditionOr, len(m.Status), func(i int) bool { return resp.Status == m.Status[i] })
+	case TemplateMatcherSize:
+		matched = matchTemplateCondition(TemplateConditionOr, len(m.Size), func(i int) bool { return len(resp.Data) == m.Size[i] })
+	case TemplateMatcherWord:
+		if m.CaseInsensitive {
+			content = strings.ToLower(content)
+		}
+		matched = matchTemplateCondition(m.Condition, len(m.Words), func(i int) bool {
+			if m.CaseInsensitive {
+				return strings.Contains(content, strings.ToLower(m.Words[i]))
+			}
+			return strings.Contains(content, m.Words[i])
+		})
+	case TemplateMatcherRegex:
+		matched = matchTemplateCondition(m.Condition, len(m.regexps), func(i int) bool { return m.regexps[i].MatchString(content) })
+	case TemplateMatcherDSL:
+		matched = matchTemplateCondition(m.Condition, len(m.dsl), func(i int) bool {
+			ok, err := evalDSL(m.dsl[i], env)
+			return err == nil && ok
+		})
+	}
+	return matched != m.Negative
+}
+
+// extract Return the distinct values the extractor finds in the response.
+func (e *TemplateExtractor) extract(resp *Response) (values []string) {
+	seen := make(map[string]bool)
+	add := func(value string) {
+		if value != "" && !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+
+	switch e.Type {
+	case TemplateExtractorRegex:
+		content := getTemplatePartContent(resp, e.Part)
+		for _, re := range e.regexps {
+			for _, match := range re.FindAllStringSubmatch(content, -1) {
+				if e.Group < len(match) {
+					add(match[e.Group])
+				}
+			}
+		}
+	case TemplateExtractorKval:
+		// Keys are header names written in lower case with _, like content_type
+		for _, key := range e.Kval {
+			for _, value := range resp.Headers.Values(strings.ReplaceAll(key, "_", "-")) {
+				add(value)
+			}
+		}
+	}
+	return values
+}
+
+// extract Record the values of the extractors, the named ones become variables of the next requests. It reports
+// whether every named extractor, or any extractor when none is named, found a value.
+func (r *TemplateRequest) extract(resp *Response, variables map[string]string, extracted map[string][]string) bool {
+	named, filled, found := 0, 0, false
+	for _, extractor := range r.Extractors {
+		if extractor.Name != "" {
+			named++
+		}
+		values := extractor.extract(resp)
+		if len(values) == 0 {
+			continue
+		}
+		found = true
+		if extractor.Name != "" {
+			variables[extractor.Name] = values[0]
+			filled++
+		}
+		if !extractor.Internal {
+			name := extractor.Name
+			if name == "" {
+				name = "extracted"
+			}
+			extracted[name] = UniqueStrList(append(extracted[name], values...))
+		}
+	}
+	if named > 0 {
+		return filled == named
+	}
+	return found
+}
+
+// RunTemplate Send the requests of the template to the base url in order, the result is nil when no request
+// matched. A request using a variable that no previous extractor filled is skipped.
+func (config *RequestClientConfig) RunTemplate(baseUrl string, template *Template) *TemplateResult {
+	variables, err := getTemplateVariables(baseUrl)
+	if err != nil {
+		return nil
+	}
+
+	var result *TemplateResult
+	extracted := make(map[string][]string)
+	for _, request := range template.Requests {
+		for _, path := range request.Path {
+			target, ok := expandTemplateVariables(path, variables)
+			if !ok {
+				continue
+			}
+
+			requestConfig := *config
+			requestConfig.Method = request.Method
+			requestConfig.FollowRedirects = request.Redirects
+			requestConfig.MaxRedirects = request.MaxRedirects
+			if requestConfig.Body, ok = expandTemplateVariables(request.Body, variables); !ok {
+				continue
+			}
+			requestConfig.Headers = make(map[string]string, len(config.Headers)+len(request.Headers))
+			for key, value := range config.Headers {
+				requestConfig.Headers[key] = value
+			}
+			for key, value := range request.Headers {
+				requestConfig.Headers[key], _ = expandTemplateVariables(value, variables)
+			}
+
+			resp, err := requestConfig.GetResponseByUrl(target)
+			if err != nil {
+				continue
+			}
+
+			// A step without matchers only extracts, the other paths are skipped once its variables are filled
+			if len(request.Matchers) == 0 {
+				if request.extract(resp, variables, extracted) {
+					break
+				}
+				continue
+			}
+
+			// Values are only taken from a response which matched, not from an error page of another path
+			env := getTemplateDSLEnv(resp, variables)
+			matched := matchTemplateCondition(request.MatchersCondition, len(request.Matchers), func(i int) bool {
+				return request.Matchers[i].match(resp, env)
+			})
+			if !matched {
+				continue
+			}
+			request.extract(resp, variables, extracted)
+			if result == nil {
+				var tags []string
+				for _, tag := range strings.Split(template.Info.Tags, ",") {
+					tags = append(tags, strings.TrimSpace(tag))
+				}
+				result = &TemplateResult{
+					ID:       template.ID,
+					Name:     template.Info.Name,
+					Severity: template.Info.Severity,
+					Tags:     UniqueStrList(tags),
+					Url:      target,
+					Method:   request.Method,
+					Status:   resp.Status,
+				}
+			}
+			break
+		}
+	}
+
+	if result != nil && len(extracted) > 0 {
+		result.Extracted = extracted
+	}
+	return result
+}
+
+// RunTemplates Run every template on the base url, the matches are sorted by severity then id.
+func (config *RequestClientConfig) RunTemplates(baseUrl string, templates []*Template) (results []TemplateResult) {
+	for _, template := range templates {
+		if result := config.RunTemplate(baseUrl, template); result != nil {
+			results = append(results, *result)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Severity != results[j].Severity {
+			return ruleSeverityRank[results[i].Severity] < ruleSeverityRank[results[j].Severity]
+		}
+		return results[i].ID < results[j].ID
+	})
+	return results
+}
@@ -0,0 +1,375 @@
+package utilz
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A small subset of the nuclei DSL: literals, variables, comparisons, !, && and || with parentheses, and the
+// contains(s, sub), regex("pattern", s), len(s), tolower(s) and toupper(s) functions.
+
+// dslNode A parsed DSL expression.
+type dslNode interface {
+	eval(env map[string]interface{}) (interface{}, error)
+}
+
+type dslLiteral struct{ value interface{} }
+
+type dslVariable struct{ name string }
+
+type dslNot struct{ node dslNode }
+
+type dslBinary struct {
+	op          string
+	left, right dslNode
+}
+
+type dslCall struct {
+	name string
+	args []dslNode
+	re   *regexp.Regexp
+}
+
+// dslFunctions The functions and their number of arguments.
+var dslFunctions = map[string]int{"contains": 2, "regex": 2, "len": 1, "tolower": 1, "toupper": 1}
+
+// parseDSL Parse an expression, which must evaluate to a boolean.
+func parseDSL(expression string) (dslNode, error) {
+	tokens, err := tokenizeDSL(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &dslParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("dsl %q: unexpected %q", expression, p.tokens[p.pos].text)
+	}
+	return node, nil
+}
+
+// evalDSL Evaluate a boolean expression.
+func evalDSL(node dslNode, env map[string]interface{}) (bool, error) {
+	value, err := node.eval(env)
+	if err != nil {
+		return false, err
+	}
+	matched, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("dsl: %v is not a boolean", value)
+	}
+	return matched, nil
+}
+
+const (
+	dslTokenString = iota
+	dslTokenNumber
+	dslTokenIdent
+	dslTokenOperator
+)
+
+type dslToken struct {
+	kind int
+	text string
+}
+
+// tokenizeDSL Split the expression in strings, numbers, identifiers and operators.
+func tokenizeDSL(expression string) ([]dslToken, error) {
+	var tokens []dslToken
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"' || c == '\'':
+			var value strings.Builder
+			j := i + 1
+			for ; j < len(expression) && expression[j] != c; j++ {
+				if expression[j] == '\\' && j+1 < len(expression) {
+					j++
+				}
+				value.WriteByte(expression[j])
+			}
+			if j == len(expression) {
+				return nil, fmt.Errorf("dsl %q: unterminated string", expression)
+			}
+			tokens = append(tokens, dslToken{dslTokenString, value.String()})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(expression) && (expression[j] >= '0' && expression[j] <= '9' || expression[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, dslToken{dslTokenNumber, expression[i:j]})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(expression) && (expression[j] == '_' || expression[j] >= 'a' && expression[j] <= 'z' ||
+				expression[j] >= 'A' && expression[j] <= 'Z' || expression[j] >= '0' && expression[j] <= '9') {
+				j++
+			}
+			tokens = append(tokens, dslToken{dslTokenIdent, expression[i:j]})
+			i = j
+		default:
+			operator := ""
+			for _, op := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", ","} {
+				if strings.HasPrefix(expression[i:], op) {
+					operator = op
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("dsl %q: unexpected %q", expression, c)
+			}
+			tokens = append(tokens, dslToken{dslTokenOperator, operator})
+			i += len(operator)
+		}
+	}
+	return tokens, nil
+}
+
+type dslParser struct {
+	tokens []dslToken
+	pos    int
+}
+
+// accept Consume the operator when it is the next token.
+func (p *dslParser) accept(operator string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == dslTokenOperator && p.tokens[p.pos].text == operator {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *dslParser) parseOr() (dslNode, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.accept("||") {
+		var right dslNode
+		if right, err = p.parseAnd(); err == nil {
+			left = &dslBinary{op: "||", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *dslParser) parseAnd() (dslNode, error) {
+	left, err := p.parseComparison()
+	for err == nil && p.accept("&&") {
+		var right dslNode
+		if right, err = p.parseComparison(); err == nil {
+			left = &dslBinary{op: "&&", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *dslParser) parseComparison() (dslNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.accept(op) {
+			right, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			return &dslBinary{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *dslParser) parseUnary() (dslNode, error) {
+	if p.accept("!") {
+		node, err := p.parseUnary()
+		return &dslNot{node: node}, err
+	}
+	if p.accept("(") {
+		node, err := p.parseOr()
+		if err == nil && !p.accept(")") {
+			err = fmt.Errorf("dsl: missing )")
+		}
+		return node, err
+	}
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("dsl: unexpected end")
+	}
+
+	token := p.tokens[p.pos]
+	p.pos++
+	switch token.kind {
+	case dslTokenString:
+		return &dslLiteral{value: token.text}, nil
+	case dslTokenNumber:
+		number, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("dsl: bad number %q", token.text)
+		}
+		return &dslLiteral{value: number}, nil
+	case dslTokenIdent:
+		switch token.text {
+		case "true":
+			return &dslLiteral{value: true}, nil
+		case "false":
+			return &dslLiteral{value: false}, nil
+		}
+		if p.accept("(") {
+			return p.parseCall(token.text)
+		}
+		return &dslVariable{name: token.text}, nil
+	}
+	return nil, fmt.Errorf("dsl: unexpected %q", token.text)
+}
+
+func (p *dslParser) parseCall(name string) (dslNode, error) {
+	arity, ok := dslFunctions[name]
+	if !ok {
+		return nil, fmt.Errorf("dsl: unknown function %s", name)
+	}
+	call := &dslCall{name: name}
+	for !p.accept(")") {
+		if len(call.args) > 0 && !p.accept(",") {
+			return nil, fmt.Errorf("dsl: %s: missing , or )", name)
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	if len(call.args) != arity {
+		return nil, fmt.Errorf("dsl: %s takes %d arguments", name, arity)
+	}
+
+	// The pattern is compiled once, so it must be a literal
+	if name == "regex" {
+		literal, ok := call.args[0].(*dslLiteral)
+		if !ok {
+			return nil, fmt.Errorf("dsl: regex needs a literal pattern")
+		}
+		pattern, ok := literal.value.(string)
+		if !ok {
+			return nil, fmt.Errorf("dsl: regex needs a literal pattern")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("dsl: %w", err)
+		}
+		call.re = re
+	}
+	return call, nil
+}
+
+func (n *dslLiteral) eval(map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n *dslVariable) eval(env map[string]interface{}) (interface{}, error) {
+	value, ok := env[n.name]
+	if !ok {
+		return nil, fmt.Errorf("dsl: unknown variable %s", n.name)
+	}
+	return value, nil
+}
+
+func (n *dslNot) eval(env map[string]interface{}) (interface{}, error) {
+	value, err := evalDSL(n.node, env)
+	return !value, err
+}
+
+func (n *dslBinary) eval(env map[string]interface{}) (interface{}, error) {
+	if n.op == "&&" || n.op == "||" {
+		left, err := evalDSL(n.left, env)
+		if err != nil || left == (n.op == "||") {
+			return left, err
+		}
+		return evalDSL(n.right, env)
+	}
+
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	leftNumber, leftOk := dslNumber(left)
+	rightNumber, rightOk := dslNumber(right)
+	if leftOk && rightOk {
+		switch n.op {
+		case "==":
+			return leftNumber == rightNumber, nil
+		case "!=":
+			return leftNumber != rightNumber, nil
+		case "<":
+			return leftNumber < rightNumber, nil
+		case "<=":
+			return leftNumber <= rightNumber, nil
+		case ">":
+			return leftNumber > rightNumber, nil
+		case ">=":
+			return leftNumber >= rightNumber, nil
+		}
+	}
+	switch n.op {
+	case "==":
+		return dslString(left) == dslString(right), nil
+	case "!=":
+		return dslString(left) != dslString(right), nil
+	}
+	return nil, fmt.Errorf("dsl: %v %s %v needs numbers", left, n.op, right)
+}
+
+func (n *dslCall) eval(env map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+
+	switch n.name {
+	case "contains":
+		return strings.Contains(dslString(args[0]), dslString(args[1])), nil
+	case "regex":
+		return n.re.MatchString(dslString(args[1])), nil
+	case "len":
+		return float64(len(dslString(args[0]))), nil
+	case "tolower":
+		return strings.ToLower(dslString(args[0])), nil
+	case "toupper":
+		return strings.ToUpper(dslString(args[0])), nil
+	}
+	return nil, fmt.Errorf("dsl: unknown function %s", n.name)
+}
+
+// dslNumber Return the value as a number, numeric strings included.
+func dslNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case string:
+		number, err := strconv.ParseFloat(v, 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+func dslString(value interface{}) string {
+	if number, ok := value.(float64); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
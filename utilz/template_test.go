@@ -0,0 +1,193 @@
+package utilz
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestRunBundledTemplates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/.env":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("APP_NAME=demo\nDB_PASSWORD=hunter2\n"))
+		case "/app/.git/HEAD":
+			w.Write([]byte("ref: refs/heads/main\n"))
+		case "/app/.git/refs/heads/main":
+			w.Write([]byte("0123456789abcdef0123456789abcdef01234567\n"))
+		case "/app/actuator/env":
+			// Served as HTML by a catch-all page, the dsl matcher rejects it
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`{"activeProfiles": ["prod"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	templates, err := LoadTemplates("../data/templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &RequestClientConfig{Headers: map[string]string{}, Timeout: 5}
+	results := config.RunTemplates(server.URL+"/app/", templates)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", results)
+	}
+
+	env := results[0]
+	if env.ID != "exposed-env-file" || env.Url != server.URL+"/app/.env" || env.Status != 200 {
+		t.Errorf("env: %+v", env)
+	}
+	if keys := env.Extracted["env_keys"]; len(keys) != 2 || keys[1] != "DB_PASSWORD" {
+		t.Errorf("env keys: %v", keys)
+	}
+
+	// The second request uses the ref extracted by the first one, which is internal
+	git := results[1]
+	if git.ID != "exposed-git-repository" || git.Url != server.URL+"/app/.git/refs/heads/main" {
+		t.Errorf("git: %+v", git)
+	}
+	if _, ok := git.Extracted["ref"]; ok || len(git.Extracted["commit"]) != 1 {
+		t.Errorf("git extracted: %v", git.Extracted)
+	}
+}
+
+func TestTemplateRequestAndMatchers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method == http.MethodPost && r.Header.Get("X-Token") == "abc" && string(body) == `{"user":"admin"}` {
+			w.Header().Set("X-Session", "s1")
+			w.Write([]byte("welcome admin"))
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	file := writeTestFile(t, dir, "login.yaml", `id: login
+info:
+  severity: low
+  tags: auth, test
+requests:
+  - method: post
+    path: ["{{RootURL}}/login"]
+    headers:
+      X-Token: abc
+    body: '{"user":"admin"}'
+    matchers-condition: and
+    matchers:
+      - type: word
+        words: [WELCOME, ADMIN]
+        condition: and
+        case-insensitive: true
+      - type: size
+        size: [13]
+      - type: dsl
+        dsl:
+          - 'status_code == 200 && (len(body) > 5 || false) && !contains(body, "denied")'
+          - 'x_session == "s1"'
+        condition: and
+    extractors:
+      - type: kval
+        kval: [x_session]
+`)
+
+	template, err := LoadTemplate(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &RequestClientConfig{Headers: map[string]string{}, Timeout: 5}
+	result := config.RunTemplate(server.URL+"/some/page", template)
+	if result == nil {
+		t.Fatal("login should match")
+	}
+	if result.Method != "POST" || result.Name != "login" || len(result.Tags) != 2 || result.Tags[1] != "test" || result.Extracted["extracted"][0] != "s1" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestTemplateExtractionsFromMatches(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		switch r.URL.Path {
+		case "/token-a", "/token-b":
+			w.Write([]byte("token=t1"))
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("version 0.1 not found"))
+		case "/info":
+			if r.Header.Get("X-Token") == "t1" {
+				w.Write([]byte("version 2.4"))
+			}
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	file := writeTestFile(t, dir, "version.yaml", `id: version
+http:
+  - path: ["{{BaseURL}}/token-a", "{{BaseURL}}/token-b"]
+    extractors:
+      - type: regex
+        name: token
+        regex: ['token=(\w+)']
+        group: 1
+        internal: true
+  - path: ["{{BaseURL}}/missing", "{{BaseURL}}/info"]
+    headers:
+      X-Token: "{{token}}"
+    matchers:
+      - type: status
+        status: [200]
+    extractors:
+      - type: regex
+        name: version
+        regex: ['version ([0-9.]+)']
+        group: 1
+`)
+
+	template, err := LoadTemplate(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &RequestClientConfig{Headers: map[string]string{}, Timeout: 5}
+	result := config.RunTemplate(server.URL, template)
+	if result == nil {
+		t.Fatal("version should match")
+	}
+	if versions := result.Extracted["version"]; len(versions) != 1 || versions[0] != "2.4" {
+		t.Errorf("only the matching response should be extracted: %v", result.Extracted)
+	}
+	for _, path := range requested {
+		if path == "/token-b" {
+			t.Errorf("the token was already extracted, requested: %v", requested)
+		}
+	}
+}
+
+func TestLoadTemplateErrors(t *testing.T) {
+	dir := t.TempDir()
+	for i, content := range []string{
+		`info: {name: no id}`,
+		`{id: a}`,
+		`{id: a, http: [{path: ["/"], matchers: [{type: cookie, words: [x]}]}]}`,
+		`{id: a, http: [{path: ["/"], matchers: [{type: word}]}]}`,
+		`{id: a, http: [{path: ["/"], matchers: [{type: dsl, dsl: ['len(body) >']}]}]}`,
+		`{id: a, http: [{path: ["/"], matchers: [{type: dsl, dsl: ['unknown(body)']}]}]}`,
+		`{id: a, http: [{path: ["/"], matchers-condition: xor, matchers: [{type: status, status: [200]}]}]}`,
+		`{id: a, info: {severity: urgent}, http: [{path: ["/"]}]}`,
+	} {
+		file := writeTestFile(t, dir, "template.yaml", content)
+		if _, err := LoadTemplate(file); err == nil {
+			t.Errorf("%d %s: expected an error", i, content)
+		}
+		os.Remove(file)
+	}
+}
@@ -0,0 +1,114 @@
+package utilz
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timing Latency breakdown of a request in milliseconds. The phases are those of the last
+// connection used, Total covers the whole request including retries, redirects and the body read.
+// A Reused keep-alive connection has no DNS, connect nor TLS phase.
+type Timing struct {
+	DNS          int64 `json:"dns_ms"`
+	Connect      int64 `json:"connect_ms"`
+	TLSHandshake int64 `json:"tls_handshake_ms"`
+	TTFB         int64 `json:"ttfb_ms"`
+	Total        int64 `json:"total_ms"`
+	Reused       bool  `json:"reused"`
+}
+
+// timingTrace Collect the httptrace events of a request.
+type timingTrace struct {
+	mu        sync.Mutex
+	start     time.Time
+	getConn   time.Time
+	dnsStart  time.Time
+	dns       time.Duration
+	connStart time.Time
+	connect   time.Duration
+	tlsStart  time.Time
+	tls       time.Duration
+	ttfb      time.Duration
+	reused    bool
+	remoteIP  string
+}
+
+func newTimingTrace() *timingTrace {
+	return &timingTrace{start: time.Now()}
+}
+
+// clientTrace Return the httptrace hooks feeding the timing trace.
+func (t *timingTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// A new hop starts, its phases replace the previous ones
+			t.getConn = time.Now()
+			t.dns, t.connect, t.tls = 0, 0, 0
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dns = time.Since(t.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.connStart = time.Now()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if err == nil {
+				t.connect = time.Since(t.connStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tls = time.Since(t.tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.reused = info.Reused
+			if host, _, err := net.SplitHostPort(info.Conn.RemoteAddr().String()); err == nil {
+				t.remoteIP = host
+			}
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.ttfb = time.Since(t.getConn)
+		},
+	}
+}
+
+// timing Return the latency breakdown and the remote IP of the last connection.
+func (t *timingTrace) timing() (*Timing, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return &Timing{
+		DNS:          t.dns.Milliseconds(),
+		Connect:      t.connect.Milliseconds(),
+		TLSHandshake: t.tls.Milliseconds(),
+		TTFB:         t.ttfb.Milliseconds(),
+		Total:        time.Since(t.start).Milliseconds(),
+		Reused:       t.reused,
+	}, t.remoteIP
+}
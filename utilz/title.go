@@ -52,7 +52,7 @@ func getTitleWithDom(r *Response) (*html.Node, error) {
 			crawler(child)
 		}
 	}
-	htmlDoc, err := html.Parse(bytes.NewReader(r.Data))
+	htmlDoc, err := r.Document()
 	if err != nil {
 		return nil, err
 	}
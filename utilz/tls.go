@@ -0,0 +1,188 @@
+package utilz
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TLSOptions TLS settings of the client. Empty options verify certificates against the system roots.
+type TLSOptions struct {
+	InsecureSkipVerify bool
+	// CAFile PEM bundle of the roots certificates are verified against, instead of the system ones.
+	CAFile string
+	// CertFile and KeyFile PEM client certificate and key, for mutual TLS.
+	CertFile string
+	KeyFile  string
+	// MinVersion and MaxVersion "1.0", "1.1", "1.2" or "1.3".
+	MinVersion string
+	MaxVersion string
+	// ServerName SNI and verified name, instead of the url host.
+	ServerName string
+}
+
+// caPoolCache Root pools by CA file, shared by the transports and the TLS info verification.
+var caPoolCache sync.Map
+
+// TLSInfo Handshake and leaf certificate details of a TLS connection.
+type TLSInfo struct {
+	Version           string    `json:"version"`
+	Cipher            string    `json:"cipher"`
+	ALPN              string    `json:"alpn"`
+	SubjectCN         string    `json:"subject_cn"`
+	SANs              []string  `json:"sans"`
+	Issuer            string    `json:"issuer"`
+	NotBefore         time.Time `json:"not_before"`
+	NotAfter          time.Time `json:"not_after"`
+	Serial            string    `json:"serial"`
+	FingerprintSHA256 string    `json:"fingerprint_sha256"`
+	Verified          bool      `json:"verified"`
+	VerifyError       string    `json:"verify_error,omitempty"`
+	SelfSigned        bool      `json:"self_signed"`
+	Expired           bool      `json:"expired"`
+}
+
+var tlsVersionNames = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// LoadTLSConfig Build the client TLS configuration of the options.
+// Sessions are cached so that new connections to a host resume the TLS session.
+func LoadTLSConfig(options TLSOptions) (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: options.InsecureSkipVerify,
+		ServerName:         options.ServerName,
+		ClientSessionCache: tls.NewLRUClientSessionCache(0),
+	}
+
+	roots, err := loadCAPool(options.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	config.RootCAs = roots
+
+	if options.CertFile != "" || options.KeyFile != "" {
+		if options.CertFile == "" || options.KeyFile == "" {
+			return nil, errors.New("a client certificate needs both the certificate and the key files")
+		}
+		cert, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if config.MinVersion, err = parseTLSVersion(options.MinVersion); err != nil {
+		return nil, err
+	}
+	if config.MaxVersion, err = parseTLSVersion(options.MaxVersion); err != nil {
+		return nil, err
+	}
+	if config.MinVersion != 0 && config.MaxVersion != 0 && config.MinVersion > config.MaxVersion {
+		return nil, fmt.Errorf("TLS min version %s is above max version %s", options.MinVersion, options.MaxVersion)
+	}
+	return config, nil
+}
+
+// loadCAPool Load the PEM bundle, nil means the system roots.
+func loadCAPool(caFile string) (*x509.CertPool, error) {
+	if caFile == "" {
+		return nil, nil
+	}
+	if pool, ok := caPoolCache.Load(caFile); ok {
+		return pool.(*x509.CertPool), nil
+	}
+
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("CA bundle %s: no PEM certificate found", caFile)
+	}
+	caPoolCache.Store(caFile, pool)
+	return pool, nil
+}
+
+// parseTLSVersion Parse "1.0" to "1.3", an empty version means the Go default.
+func parseTLSVersion(version string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToLower(strings.TrimSpace(version)), "tls") {
+	case "":
+		return 0, nil
+	case "1.0", "10":
+		return tls.VersionTLS10, nil
+	case "1.1", "11":
+		return tls.VersionTLS11, nil
+	case "1.2", "12":
+		return tls.VersionTLS12, nil
+	case "1.3", "13":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unknown TLS version %q, expected 1.0, 1.1, 1.2 or 1.3", version)
+}
+
+// GetTLSInfo Extract the handshake details of the connection state, the chain is verified for host
+// against roots, nil meaning the system roots.
+func GetTLSInfo(state *tls.ConnectionState, host string, roots *x509.CertPool) *TLSInfo {
+	if state == nil {
+		return nil
+	}
+
+	info := &TLSInfo{
+		Version: tlsVersionNames[state.Version],
+		Cipher:  tls.CipherSuiteName(state.CipherSuite),
+		ALPN:    state.NegotiatedProtocol,
+	}
+	if info.Version == "" {
+		info.Version = fmt.Sprintf("0x%04x", state.Version)
+	}
+
+	if len(state.PeerCertificates) == 0 {
+		return info
+	}
+	leaf := state.PeerCertificates[0]
+
+	info.SubjectCN = leaf.Subject.CommonName
+	info.SANs = append(info.SANs, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+	info.Issuer = leaf.Issuer.String()
+	info.NotBefore = leaf.NotBefore
+	info.NotAfter = leaf.NotAfter
+	info.Serial = fmt.Sprintf("%X", leaf.SerialNumber)
+	fingerprint := sha256.Sum256(leaf.Raw)
+	info.FingerprintSHA256 = hex.EncodeToString(fingerprint[:])
+
+	info.Expired = time.Now().After(leaf.NotAfter)
+	info.SelfSigned = bytes.Equal(leaf.RawIssuer, leaf.RawSubject) && leaf.CheckSignatureFrom(leaf) == nil
+
+	// Verify the chain as a client with verification enabled would have.
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Intermediates: intermediates,
+		Roots:         roots,
+	})
+	info.Verified = err == nil
+	if err != nil {
+		info.VerifyError = err.Error()
+	}
+
+	return info
+}
@@ -0,0 +1,156 @@
+package utilz
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeClientCert Write a self-signed client certificate and its key as PEM files.
+func writeClientCert(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "httpxUtilz client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return cert, certFile, keyFile
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, certFile, keyFile := writeClientCert(t, dir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &RequestClientConfig{
+		Headers: map[string]string{},
+		Timeout: 5,
+		TLS:     TLSOptions{CAFile: caFile, ServerName: "example.com"},
+	}
+	if _, err := config.GetResponseByUrl(server.URL); err == nil {
+		t.Fatal("expected the request without client certificate to fail")
+	}
+
+	config.TLS.CertFile, config.TLS.KeyFile = certFile, keyFile
+	resp, err := config.GetResponseByUrl(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != http.StatusOK || resp.TLS == nil || !resp.TLS.Verified {
+		t.Fatalf("got status %d, tls %+v", resp.Status, resp.TLS)
+	}
+}
+
+func TestLoadTLSConfigErrors(t *testing.T) {
+	for _, options := range []TLSOptions{
+		{CAFile: "/nonexistent/ca.pem"},
+		{CertFile: "/nonexistent/client.pem"},
+		{MinVersion: "1.4"},
+		{MinVersion: "1.3", MaxVersion: "1.2"},
+	} {
+		if _, err := LoadTLSConfig(options); err == nil {
+			t.Errorf("%+v: expected an error", options)
+		}
+	}
+
+	config, err := LoadTLSConfig(TLSOptions{MinVersion: "1.2", MaxVersion: "tls1.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MinVersion != tls.VersionTLS12 || config.MaxVersion != tls.VersionTLS13 {
+		t.Fatalf("got versions %x-%x", config.MinVersion, config.MaxVersion)
+	}
+}
+
+func TestGetTLSInfo(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	// The response of the scanner, which doesn't verify but reports the certificate
+	config := &RequestClientConfig{Headers: map[string]string{}, Timeout: 5, UseHTTPS: true}
+	resp, err := config.GetResponseByUrl(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := resp.TLS
+	if info == nil {
+		t.Fatal("no TLS info")
+	}
+	fingerprint := sha256.Sum256(server.Certificate().Raw)
+	if info.Version == "" || info.Cipher == "" || info.FingerprintSHA256 != hex.EncodeToString(fingerprint[:]) {
+		t.Errorf("unexpected handshake details: %+v", info)
+	}
+	if !info.SelfSigned || info.Expired || info.Verified || info.VerifyError == "" {
+		t.Errorf("the test certificate is self-signed and unknown to the system roots: %+v", info)
+	}
+	sans := strings.Join(info.SANs, ",")
+	if !strings.Contains(sans, "example.com") || !strings.Contains(sans, "127.0.0.1") {
+		t.Errorf("got SANs %v", info.SANs)
+	}
+
+	// Verified against the server certificate as root, for a name of the certificate only
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	httpResp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpResp.Body.Close()
+	if info := GetTLSInfo(httpResp.TLS, "example.com", roots); !info.Verified {
+		t.Errorf("example.com: %s", info.VerifyError)
+	}
+	if info := GetTLSInfo(httpResp.TLS, "other.org", roots); info.Verified || info.VerifyError == "" {
+		t.Errorf("other.org should not be verified: %+v", info)
+	}
+	if GetTLSInfo(nil, "example.com", roots) != nil {
+		t.Error("a plain http response has no TLS info")
+	}
+}
@@ -0,0 +1,95 @@
+package utilz
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"math"
+)
+
+const (
+	// vhostLengthTolerance Relative body length difference still considered the same page, dynamic pages vary a bit.
+	vhostLengthTolerance = 0.05
+	// vhostLengthSlack Bytes always tolerated on top of the Host length difference, which error pages often reflect.
+	vhostLengthSlack = 32
+)
+
+// VhostFingerprint The parts of a response compared between virtual hosts.
+type VhostFingerprint struct {
+	Host          string `json:"host"`
+	Status        int    `json:"status_code"`
+	ContentLength int    `json:"content_length"`
+	Title         string `json:"title"`
+}
+
+// VhostResult A candidate virtual host compared with the baseline answer to an unknown host.
+type VhostResult struct {
+	VhostFingerprint
+	Url      string            `json:"url"`
+	Vhost    bool              `json:"vhost"`
+	Diff     []string          `json:"diff,omitempty"`
+	Baseline *VhostFingerprint `json:"baseline"`
+}
+
+// GetVhostFingerprint Request the url, which points to an IP, with host as Host header and SNI.
+func (config *RequestClientConfig) GetVhostFingerprint(targetUrl string, host string) (*VhostFingerprint, error) {
+	vhostConfig := *config
+	vhostConfig.Host = host
+	// Redirects would leave the IP for the DNS of the candidate.
+	vhostConfig.FollowRedirects = false
+
+	resp, err := vhostConfig.GetResponseByUrl(targetUrl)
+	if err != nil {
+		return nil, err
+	}
+	return &VhostFingerprint{
+		Host:          host,
+		Status:        resp.Status,
+		ContentLength: len(resp.Data),
+		Title:         ExtractTitle(resp),
+	}, nil
+}
+
+// GetVhostBaseline Fingerprint a random host of the domain, that is what the server answers to names it doesn't serve.
+func (config *RequestClientConfig) GetVhostBaseline(targetUrl string, domain string) (*VhostFingerprint, error) {
+	return config.GetVhostFingerprint(targetUrl, randomVhost(domain))
+}
+
+// randomVhost Return a random subdomain of the domain, or of .invalid without domain.
+func randomVhost(domain string) string {
+	label := make([]byte, 6)
+	rand.Read(label) //nolint
+	if domain == "" {
+		domain = "invalid"
+	}
+	return hex.EncodeToString(label) + "." + domain
+}
+
+// CompareVhost Return what differs between the candidate and the baseline, nothing means the host is not served.
+func CompareVhost(candidate *VhostFingerprint, baseline *VhostFingerprint) (diff []string) {
+	if candidate.Status != baseline.Status {
+		diff = append(diff, "status")
+	}
+
+	slack := vhostLengthSlack + int(math.Abs(float64(len(candidate.Host)-len(baseline.Host))))
+	tolerance := int(float64(baseline.ContentLength)*vhostLengthTolerance) + slack
+	if int(math.Abs(float64(candidate.ContentLength-baseline.ContentLength))) > tolerance {
+		diff = append(diff, "length")
+	}
+
+	if candidate.Title != baseline.Title {
+		diff = append(diff, "title")
+	}
+	return
+}
+
+// GetVhostResult Compare the candidate fingerprint with the baseline.
+func GetVhostResult(targetUrl string, candidate *VhostFingerprint, baseline *VhostFingerprint) *VhostResult {
+	diff := CompareVhost(candidate, baseline)
+	return &VhostResult{
+		VhostFingerprint: *candidate,
+		Url:              targetUrl,
+		Vhost:            len(diff) > 0,
+		Diff:             diff,
+		Baseline:         baseline,
+	}
+}
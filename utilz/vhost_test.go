@@ -0,0 +1,78 @@
+package utilz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVhostProbing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Host {
+		case "admin.example.com":
+			w.Write([]byte("<html><title>Admin</title><body>login</body></html>"))
+		default:
+			// The default vhost reflects the requested host, as many error pages do.
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<html><title>Not Found</title><body>no site " + r.Host + "</body></html>"))
+		}
+	}))
+	defer server.Close()
+
+	config := &RequestClientConfig{Headers: map[string]string{}, Timeout: 5}
+	baseline, err := config.GetVhostBaseline(server.URL, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if baseline.Status != http.StatusNotFound {
+		t.Fatalf("baseline got status %d", baseline.Status)
+	}
+
+	for host, want := range map[string]bool{"admin.example.com": true, "www.example.com": false} {
+		candidate, err := config.GetVhostFingerprint(server.URL, host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := GetVhostResult(server.URL, candidate, baseline)
+		if result.Vhost != want {
+			t.Errorf("%s: got vhost %v with diff %v, want %v", host, result.Vhost, result.Diff, want)
+		}
+	}
+}
+
+func TestVhostTransportsNotPooled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	countPool := func() (n int) {
+		transportPool.Range(func(_, _ interface{}) bool {
+			n++
+			return true
+		})
+		return
+	}
+	before := countPool()
+	config := &RequestClientConfig{Headers: map[string]string{}, Timeout: 5}
+	for _, host := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		if _, err := config.GetVhostFingerprint(server.URL, host); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after := countPool(); after != before {
+		t.Errorf("every vhost candidate added a pooled transport: %d before, %d after", before, after)
+	}
+}
+
+func TestCompareVhostLength(t *testing.T) {
+	baseline := &VhostFingerprint{Host: "abc.example.com", Status: 200, ContentLength: 10000}
+	same := &VhostFingerprint{Host: "www.example.com", Status: 200, ContentLength: 10300}
+	if diff := CompareVhost(same, baseline); len(diff) != 0 {
+		t.Errorf("got diff %v for a length within tolerance", diff)
+	}
+	other := &VhostFingerprint{Host: "www.example.com", Status: 200, ContentLength: 4000}
+	if diff := CompareVhost(other, baseline); len(diff) != 1 || diff[0] != "length" {
+		t.Errorf("got diff %v, want [length]", diff)
+	}
+}
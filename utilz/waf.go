@@ -0,0 +1,226 @@
+package utilz
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// WafInfo The WAF detected in front of a target and the evidence supporting it.
+type WafInfo struct {
+	Detected bool     `json:"detected"`
+	Vendor   string   `json:"vendor"`
+	Evidence []string `json:"evidence"`
+	Probed   bool     `json:"probed"`
+}
+
+// wafSignature Cookies, headers and block page patterns of a WAF vendor.
+// Body patterns only count when the status is one of BlockStatus, if any.
+type wafSignature struct {
+	Headers     map[string]string `json:"headers"`
+	Cookies     []string          `json:"cookies"`
+	Body        []string          `json:"body"`
+	BlockStatus []int             `json:"block_status"`
+
+	vendor      string
+	headers     map[string]*regexp.Regexp
+	headerNames []string
+	body        []*regexp.Regexp
+}
+
+// wafProbePayload A harmless query string which looks like SQL injection, XSS and path traversal to a WAF.
+const wafProbePayload = `1' OR '1'='1' -- <script>alert(document.cookie)</script> ../../../../etc/passwd`
+
+// wafBlockStatus Status codes commonly used by WAFs to answer a blocked request.
+var wafBlockStatus = map[int]bool{
+	http.StatusForbidden:          true,
+	http.StatusMethodNotAllowed:   true,
+	http.StatusNotAcceptable:      true,
+	http.StatusTeapot:             true,
+	http.StatusTooManyRequests:    true,
+	http.StatusNotImplemented:     true,
+	http.StatusServiceUnavailable: true,
+	493:                           true,
+	999:                           true,
+}
+
+var (
+	wafSignaturesCache   = make(map[string][]*wafSignature)
+	wafSignaturesCacheMu sync.Mutex
+)
+
+// loadWafSignatures Read and compile the WAF signatures file, the signatures are cached by file name.
+func loadWafSignatures(filename string) ([]*wafSignature, error) {
+	wafSignaturesCacheMu.Lock()
+	defer wafSignaturesCacheMu.Unlock()
+
+	if signatures, ok := wafSignaturesCache[filename]; ok {
+		return signatures, nil
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("loadWafSignatures> failed to read the file: %w", err)
+	}
+
+	var rawSignatures map[string]*wafSignature
+	if err := json.Unmarshal(data, &rawSignatures); err != nil {
+		return nil, fmt.Errorf("loadWafSignatures> failed to parse JSON: %w", err)
+	}
+
+	signatures := make([]*wafSignature, 0, len(rawSignatures))
+	for vendor, signature := range rawSignatures {
+		signature.vendor = vendor
+		signature.headers = make(map[string]*regexp.Regexp)
+		for header, pattern := range signature.Headers {
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				log.Printf("loadWafSignatures> %s: invalid pattern %q: %v", vendor, pattern, err)
+				continue
+			}
+			signature.headers[header] = re
+			signature.headerNames = append(signature.headerNames, header)
+		}
+		// Evidence in a stable order
+		sort.Strings(signature.headerNames)
+		for _, pattern := range signature.Body {
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				log.Printf("loadWafSignatures> %s: invalid pattern %q: %v", vendor, pattern, err)
+				continue
+			}
+			signature.body = append(signature.body, re)
+		}
+		signatures = append(signatures, signature)
+	}
+	sort.Slice(signatures, func(i, j int) bool { return signatures[i].vendor < signatures[j].vendor })
+
+	wafSignaturesCache[filename] = signatures
+	return signatures, nil
+}
+
+// match Return the evidence of the signature found on the response.
+func (signature *wafSignature) match(resp *Response) (evidence []string) {
+	for _, header := range signature.headerNames {
+		re := signature.headers[header]
+		for _, value := range resp.Headers.Values(header) {
+			if re.MatchString(value) {
+				evidence = append(evidence, fmt.Sprintf("header %s: %s", header, value))
+				break
+			}
+		}
+	}
+
+	for _, cookie := range (&http.Response{Header: resp.Headers}).Cookies() {
+		for _, prefix := range signature.Cookies {
+			if strings.HasPrefix(strings.ToLower(cookie.Name), strings.ToLower(prefix)) {
+				evidence = append(evidence, "cookie "+cookie.Name)
+			}
+		}
+	}
+
+	blockStatus := len(signature.BlockStatus) == 0
+	for _, status := range signature.BlockStatus {
+		if resp.Status == status {
+			blockStatus = true
+		}
+	}
+	if blockStatus {
+		for _, re := range signature.body {
+			if match := re.FindString(resp.Raw); match != "" {
+				if len(match) > 80 {
+					match = match[:80]
+				}
+				evidence = append(evidence, fmt.Sprintf("body (status %d): %s", resp.Status, match))
+			}
+		}
+	}
+
+	return
+}
+
+// DetectWafByResponse Return the vendor whose signatures match the response best, with the evidence.
+func DetectWafByResponse(resp *Response, signaturesFile string) (vendor string, evidence []string) {
+	signatures, err := loadWafSignatures(signaturesFile)
+	if err != nil {
+		log.Println("DetectWafByResponse> ", err)
+		return
+	}
+
+	for _, signature := range signatures {
+		if signatureEvidence := signature.match(resp); len(signatureEvidence) > len(evidence) {
+			vendor = signature.vendor
+			evidence = signatureEvidence
+		}
+	}
+	return
+}
+
+// getProbeFailure Return how the probe connection failed: "reset" when it was reset or closed without
+// an answer, "dropped" when it timed out, empty for the other errors.
+func getProbeFailure(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "reset"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "dropped"
+	}
+	return ""
+}
+
+// GetWafInfoByProbe Send one attack looking request to the target and compare its response with the baseline.
+func (config *RequestClientConfig) GetWafInfoByProbe(targetUrl string, baseline *Response, signaturesFile string) (blocked bool, vendor string, evidence []string) {
+	probeUrl, err := url.Parse(targetUrl)
+	if err != nil {
+		log.Println("GetWafInfoByProbe> ", err)
+		return
+	}
+	query := probeUrl.Query()
+	query.Set("id", wafProbePayload)
+	probeUrl.RawQuery = query.Encode()
+
+	probeConfig := *config
+	probeConfig.Method = http.MethodGet
+	probe, err := probeConfig.GetResponseByUrl(probeUrl.String())
+	if err != nil {
+		// A reset or dropped connection only for the probe is a typical WAF answer, if the target answered
+		// the baseline and a second probe fails the same way. Other errors say nothing of a WAF.
+		failure := getProbeFailure(err)
+		if baseline == nil || failure == "" {
+			return
+		}
+		var retryErr error
+		if probe, retryErr = probeConfig.GetResponseByUrl(probeUrl.String()); retryErr != nil {
+			if getProbeFailure(retryErr) != failure {
+				return
+			}
+			evidence = append(evidence, fmt.Sprintf("probe connection %s twice: %v", failure, err))
+			return true, "", evidence
+		}
+	}
+	if baseline == nil {
+		return
+	}
+
+	vendor, evidence = DetectWafByResponse(probe, signaturesFile)
+	if probe.Status != baseline.Status && wafBlockStatus[probe.Status] {
+		blocked = true
+		evidence = append(evidence, fmt.Sprintf("probe status %d, baseline status %d", probe.Status, baseline.Status))
+	}
+	if vendor != "" {
+		blocked = true
+	}
+	return
+}
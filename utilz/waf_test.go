@@ -0,0 +1,107 @@
+package utilz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const wafSignaturesFile = "../data/waf_signatures.json"
+
+func TestWafSignaturesFile(t *testing.T) {
+	signatures, err := loadWafSignatures(wafSignaturesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signatures) < 10 {
+		t.Fatalf("got %d signatures", len(signatures))
+	}
+	for _, signature := range signatures {
+		if len(signature.headers) != len(signature.Headers) || len(signature.body) != len(signature.Body) {
+			t.Errorf("%s: a pattern doesn't compile", signature.vendor)
+		}
+		if len(signature.Headers)+len(signature.Cookies)+len(signature.Body) == 0 {
+			t.Errorf("%s: no signature", signature.vendor)
+		}
+	}
+}
+
+func TestDetectWafByResponse(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		resp   *Response
+		vendor string
+	}{
+		{"cloudflare headers", &Response{Status: 200, Headers: http.Header{"Server": {"cloudflare"}, "Cf-Ray": {"7d1c2f3a4b5c6d7e-FRA"}}}, "Cloudflare"},
+		{"incapsula cookie", &Response{Status: 200, Headers: http.Header{"Set-Cookie": {"visid_incap_123=abc; path=/"}}}, "Imperva Incapsula"},
+		{"modsecurity block page", &Response{Status: 403, Headers: http.Header{}, Raw: "<p>This error was generated by Mod_Security.</p>"}, "ModSecurity"},
+		{"block page on a 200", &Response{Status: 200, Headers: http.Header{}, Raw: "This error was generated by Mod_Security"}, ""},
+		{"plain site", &Response{Status: 200, Headers: http.Header{"Server": {"nginx"}}, Raw: "<html>hello</html>"}, ""},
+	} {
+		vendor, evidence := DetectWafByResponse(test.resp, wafSignaturesFile)
+		if vendor != test.vendor {
+			t.Errorf("%s: got vendor %q with evidence %v, want %q", test.name, vendor, evidence, test.vendor)
+		}
+		if vendor != "" && len(evidence) == 0 {
+			t.Errorf("%s: no evidence", test.name)
+		}
+		for i := 0; i < 10; i++ {
+			if _, again := DetectWafByResponse(test.resp, wafSignaturesFile); strings.Join(again, "|") != strings.Join(evidence, "|") {
+				t.Fatalf("%s: got evidence %v, then %v", test.name, evidence, again)
+			}
+		}
+	}
+}
+
+func TestGetWafInfoByProbe(t *testing.T) {
+	var probes int32
+	// resetProbes Reset the first n probes, then answer them like the baseline
+	newServer := func(resetProbes int32, probeStatus int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.Contains(r.URL.RawQuery, "id=") {
+				w.Write([]byte("home"))
+				return
+			}
+			if atomic.AddInt32(&probes, 1) <= resetProbes {
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+			w.WriteHeader(probeStatus)
+		}))
+	}
+	config := &RequestClientConfig{Headers: map[string]string{}, Timeout: 5}
+	baseline := &Response{Status: 200, Headers: http.Header{}}
+
+	for _, test := range []struct {
+		name        string
+		resetProbes int32
+		probeStatus int
+		blocked     bool
+	}{
+		{"reset every probe", 100, 200, true},
+		{"reset once", 1, 200, false},
+		{"block status", 0, http.StatusForbidden, true},
+		{"same answer", 0, 200, false},
+	} {
+		atomic.StoreInt32(&probes, 0)
+		server := newServer(test.resetProbes, test.probeStatus)
+		blocked, _, evidence := config.GetWafInfoByProbe(server.URL, baseline, wafSignaturesFile)
+		if blocked != test.blocked {
+			t.Errorf("%s: got blocked %v with evidence %v", test.name, blocked, evidence)
+		}
+		server.Close()
+	}
+
+	// A closed port says nothing of a WAF
+	server := newServer(0, 200)
+	server.Close()
+	if blocked, _, evidence := config.GetWafInfoByProbe(server.URL, baseline, wafSignaturesFile); blocked {
+		t.Errorf("refused connection: got blocked with evidence %v", evidence)
+	}
+	if blocked, _, _ := config.GetWafInfoByProbe(server.URL, nil, wafSignaturesFile); blocked {
+		t.Error("no baseline: got blocked")
+	}
+}